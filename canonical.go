@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// canonicalizer turns URLs into the canonical form that -D compares, so that
+// "HTTP://Example.com:80/a?b=1&a=2" and "http://example.com/a?a=2&b=1" count
+// as the same URL.
+type canonicalizer struct {
+	dropFragment bool // --drop-fragment: "#..." is ignored
+	emit         bool // --write-canonical: output the canonical form too
+}
+
+// form returns the canonical form of rawURL: lowercase scheme and host, no
+// default port, "/" for an empty path, query parameters sorted by name and
+// percent-escapes in upper case. Parameters with the same name keep their
+// order, since "a=1&a=2" and "a=2&a=1" may well behave differently.
+// URLs that don't parse are returned unchanged.
+func (c *canonicalizer) form(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.Opaque != "" {
+		return rawURL
+	}
+	parts, ok := parseURLParts(rawURL)
+	if !ok {
+		return rawURL
+	}
+	normalizeURL(u)
+
+	var b strings.Builder
+	b.WriteString(u.Scheme + "://")
+	if u.User != nil {
+		b.WriteString(u.User.String() + "@")
+	}
+	b.WriteString(u.Host)
+	if parts.path == "" {
+		b.WriteString("/")
+	} else {
+		b.WriteString(upperEscapes(parts.path))
+	}
+
+	if parts.hasQuery {
+		params := make([]queryParam, len(parts.params))
+		for i, param := range parts.params {
+			params[i] = queryParam{name: upperEscapes(param.name), value: upperEscapes(param.value), hasValue: param.hasValue}
+		}
+		slices.SortStableFunc(params, func(a, b queryParam) int { return strings.Compare(a.name, b.name) })
+		b.WriteString("?" + joinQuery(params))
+	}
+	if parts.hasFragment && !c.dropFragment {
+		b.WriteString("#" + upperEscapes(parts.fragment))
+	}
+	return b.String()
+}
+
+// upperEscapes upper-cases the hex digits of every percent-escape, so "%2f"
+// and "%2F" compare equal.
+func upperEscapes(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	b := []byte(s)
+	for i := 0; i+2 < len(b); i++ {
+		if b[i] == '%' && isHex(b[i+1]) && isHex(b[i+2]) {
+			b[i+1], b[i+2] = upperHex(b[i+1]), upperHex(b[i+2])
+			i += 2
+		}
+	}
+	return string(b)
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func upperHex(c byte) byte {
+	if c >= 'a' && c <= 'f' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Magic bytes at the start of compressed streams.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// readCloser pairs a (possibly decompressing) reader with the closers that
+// must run when reading is done, innermost first.
+type readCloser struct {
+	io.Reader
+	closers []func() error
+}
+
+func (r *readCloser) Close() error {
+	var firstErr error
+	for _, closeFn := range r.closers {
+		if err := closeFn(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// writeCloser pairs a (possibly compressing) writer with the closers that
+// must run to finish the stream, innermost first.
+type writeCloser struct {
+	io.Writer
+	closers []func() error
+}
+
+func (w *writeCloser) Close() error {
+	var firstErr error
+	for _, closeFn := range w.closers {
+		if err := closeFn(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// decompressReader sniffs the first bytes of r and, if they match the gzip or
+// zstd magic number, returns a reader that decompresses on the fly. Anything
+// else is returned as plain text. closeFn closes the underlying input.
+func decompressReader(r io.Reader, closeFn func() error) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	// Peek errors just mean the input is shorter than the magic; treat it as plain text
+	header, _ := buffered.Peek(len(zstdMagic))
+
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			closeFn()
+			return nil, err
+		}
+		return &readCloser{Reader: gz, closers: []func() error{gz.Close, closeFn}}, nil
+	case bytes.HasPrefix(header, zstdMagic):
+		zr, err := zstd.NewReader(buffered)
+		if err != nil {
+			closeFn()
+			return nil, err
+		}
+		zstdClose := func() error {
+			zr.Close()
+			return nil
+		}
+		return &readCloser{Reader: zr, closers: []func() error{zstdClose, closeFn}}, nil
+	default:
+		return &readCloser{Reader: buffered, closers: []func() error{closeFn}}, nil
+	}
+}
+
+// createOutput creates the file at path for writing. Names ending in .gz or
+// .zst are compressed on the fly; Close finishes the stream and the file.
+func createOutput(path string) (io.WriteCloser, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		gz := gzip.NewWriter(file)
+		return &writeCloser{Writer: gz, closers: []func() error{gz.Close, file.Close}}, nil
+	case strings.HasSuffix(path, ".zst"):
+		zw, err := zstd.NewWriter(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &writeCloser{Writer: zw, closers: []func() error{zw.Close, file.Close}}, nil
+	default:
+		return file, nil
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// dedupHashes is the number of bit positions set per URL. 7 is optimal for
+// ~10 bits per entry, which gives roughly a 1% false positive rate.
+const dedupHashes = 7
+
+// dedupFilter is the fixed-size Bloom filter behind -D. Its memory is allocated
+// once up front and does not grow with the number of URLs generated, so -D can
+// run over unbounded input. The trade-off is a small chance that a URL that was
+// never emitted is treated as a duplicate and dropped.
+type dedupFilter struct {
+	bits     []uint64
+	size     uint64 // number of bits
+	added    uint64
+	capacity uint64 // entries before the false positive rate climbs past ~1%
+	warned   bool
+}
+
+// newDedupFilter allocates a filter using sizeMB megabytes of memory.
+func newDedupFilter(sizeMB int) *dedupFilter {
+	if sizeMB < 1 {
+		sizeMB = 1
+	}
+	words := uint64(sizeMB) * 1024 * 1024 / 8
+	return &dedupFilter{
+		bits:     make([]uint64, words),
+		size:     words * 64,
+		capacity: words * 64 / 10,
+	}
+}
+
+// seen reports whether s was (probably) added before, and adds it if not.
+func (f *dedupFilter) seen(s string) bool {
+	// Double hashing: derive all bit positions from two independent hashes.
+	// The hashes are fixed rather than seeded per run, so the rare unique URL
+	// that gets dropped is the same one every time and outputs stay diffable.
+	h1 := fnv1a(s, fnvOffset)
+	h2 := fnv1a(s, fnvOffsetAlt) | 1
+
+	present := true
+	for i := uint64(0); i < dedupHashes; i++ {
+		bit := (h1 + i*h2) % f.size
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if f.bits[word]&mask == 0 {
+			present = false
+			f.bits[word] |= mask
+		}
+	}
+	if present {
+		return true
+	}
+
+	f.added++
+	if f.added > f.capacity && !f.warned {
+		f.warned = true
+		fmt.Fprintf(os.Stderr, "%sWarning: -D filter holds over %d URLs; some unique URLs may now be dropped. Raise --dedup-mem for more accuracy.%s\n", colorYellow, f.capacity, colorReset)
+	}
+	return false
+}
+
+// FNV-1a 64 parameters. fnvOffsetAlt is a second offset basis, giving an
+// independent hash for double hashing.
+const (
+	fnvOffset    = 14695981039346656037
+	fnvOffsetAlt = 0x9e3779b97f4a7c15
+	fnvPrime     = 1099511628211
+)
+
+// fnv1a is the 64-bit FNV-1a hash of s, starting from basis. FNV-1a alone
+// mixes the low bits poorly for URLs differing only at the end, and the bit
+// positions come from the low bits, so the result goes through the murmur3
+// finaliser.
+func fnv1a(s string, basis uint64) uint64 {
+	h := basis
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime
+	}
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"slices"
+)
+
+// paramDiscovery is the "hidden-params" strategy, Arjun style: every unique
+// endpoint (URL without its query) gets the names from a parameter wordlist
+// added in batches, each with its own canary value. A response that reflects
+// or reacts to a canary points at the parameter it was sent in.
+type paramDiscovery struct {
+	names     []string
+	batchSize int
+	endpoints *dedupFilter // endpoints already given their batches, in fixed memory like -D
+
+	mapPath string
+	file    io.WriteCloser // JSON batch map, created on the first batch
+	writer  *bufio.Writer
+	records int
+	err     error // first error writing the batch map; reported by close
+}
+
+// discoveryBatch is one entry of the --param-map file.
+type discoveryBatch struct {
+	URL      string            `json:"url"`
+	Endpoint string            `json:"endpoint"`
+	Batch    int               `json:"batch"`
+	Params   map[string]string `json:"params"` // parameter name -> canary value
+	Input    string            `json:"input"`
+}
+
+// newParamDiscovery returns the strategy state, or nil when there is no
+// parameter wordlist. Seen endpoints are tracked in memMB megabytes.
+func newParamDiscovery(names []string, batchSize int, mapPath string, memMB int) (*paramDiscovery, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if batchSize < 1 {
+		return nil, fmt.Errorf("--param-batch must be at least 1")
+	}
+	return &paramDiscovery{names: names, batchSize: batchSize, endpoints: newDedupFilter(memMB), mapPath: mapPath}, nil
+}
+
+// variations yields the URL itself and, the first time its endpoint is seen,
+// one URL per batch of parameter names. The URL's own parameters are kept so
+// the endpoint still behaves normally; names it already has are not added.
+func (d *paramDiscovery) variations(rawURL string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		if !yield(rawURL) {
+			return
+		}
+		parts, ok := parseURLParts(rawURL)
+		if !ok {
+			return
+		}
+		endpoint := parts.prefix + parts.path
+		if d.endpoints.seen(endpoint) {
+			return
+		}
+
+		var names []string
+		for _, name := range d.names {
+			if !slices.ContainsFunc(parts.params, func(p queryParam) bool { return p.name == name }) {
+				names = append(names, name)
+			}
+		}
+
+		batch := 0
+		for chunk := range slices.Chunk(names, d.batchSize) {
+			batch++
+			params := append([]queryParam{}, parts.params...)
+			canaries := make(map[string]string, len(chunk))
+			for _, name := range chunk {
+				canaries[name] = randomHex(4)
+				params = append(params, queryParam{name: name, value: canaries[name], hasValue: true})
+			}
+			discoveryURL := parts.withParams(params)
+			if !yield(discoveryURL) {
+				return
+			}
+			d.record(discoveryBatch{URL: discoveryURL, Endpoint: endpoint, Batch: batch, Params: canaries, Input: rawURL})
+		}
+	}
+}
+
+// record appends one batch to the JSON map, which is a single array written
+// as batches are generated.
+func (d *paramDiscovery) record(batch discoveryBatch) {
+	if d.mapPath == "" || d.err != nil {
+		return
+	}
+	if d.writer == nil {
+		file, err := createOutput(d.mapPath)
+		if err != nil {
+			d.err = fmt.Errorf("creating parameter map '%s': %w", d.mapPath, err)
+			return
+		}
+		d.file = file
+		d.writer = bufio.NewWriter(file)
+		d.writer.WriteString("[\n")
+	}
+	// Keep "&" readable in the URLs rather than escaping it to \u0026
+	var entry bytes.Buffer
+	encoder := json.NewEncoder(&entry)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(batch); err != nil {
+		d.err = err
+		return
+	}
+	if d.records > 0 {
+		d.writer.WriteString(",\n")
+	}
+	d.records++
+	if _, err := d.writer.Write(bytes.TrimSuffix(entry.Bytes(), []byte("\n"))); err != nil {
+		d.err = fmt.Errorf("writing parameter map '%s': %w", d.mapPath, err)
+	}
+}
+
+// close ends the JSON array and reports the first error seen while writing it.
+func (d *paramDiscovery) close() error {
+	if d == nil {
+		return nil
+	}
+	if d.file != nil {
+		d.writer.WriteString("\n]\n")
+		if err := d.writer.Flush(); err != nil && d.err == nil {
+			d.err = fmt.Errorf("writing parameter map '%s': %w", d.mapPath, err)
+		}
+		if err := d.file.Close(); err != nil && d.err == nil {
+			d.err = err
+		}
+	}
+	return d.err
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// An encoder rewrites a payload into another representation.
+type encoder func(payload string) string
+
+// encoderNames lists the --encode steps in the order they are documented.
+var encoderNames = []string{"url", "unicode", "html", "base64"}
+
+// encoders maps each --encode step name to its implementation.
+var encoders = map[string]encoder{
+	"url":     urlEncode,
+	"unicode": unicodeEscape,
+	"html":    htmlEntities,
+	"base64":  base64Encode,
+}
+
+// payloadEncoder turns one payload into the forms that get injected: one per
+// --encode chain, plus the raw payload when there are no chains or when
+// --encode-raw asks for it.
+type payloadEncoder struct {
+	chains  [][]encoder
+	keepRaw bool
+}
+
+// newPayloadEncoder parses the --encode chains. Each chain is a comma separated
+// list of steps applied left to right, so "url,url" double URL-encodes.
+// It returns nil when no chains are given, meaning payloads are used as is.
+func newPayloadEncoder(specs []string, keepRaw bool) (*payloadEncoder, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	e := &payloadEncoder{keepRaw: keepRaw}
+	for _, spec := range specs {
+		var chain []encoder
+		for _, name := range parseKeywords(spec) {
+			step, ok := encoders[strings.ToLower(name)]
+			if !ok {
+				return nil, fmt.Errorf("unknown encoding '%s' (use %s)", name, strings.Join(encoderNames, ", "))
+			}
+			chain = append(chain, step)
+		}
+		if len(chain) == 0 {
+			return nil, fmt.Errorf("empty --encode chain")
+		}
+		e.chains = append(e.chains, chain)
+	}
+	return e, nil
+}
+
+// formChains lists the forms of payload that get injected, skipping repeats
+// (an alphanumeric payload looks the same URL-encoded as raw): -1 for the raw
+// payload, otherwise an index into e.chains.
+func (e *payloadEncoder) formChains(payload string) []int {
+	if e == nil {
+		return []int{-1}
+	}
+	var chains []int
+	var emitted []string // a handful at most, one per chain
+	add := func(chain int) {
+		form := e.encode(payload, chain)
+		for _, prev := range emitted {
+			if prev == form {
+				return
+			}
+		}
+		emitted = append(emitted, form)
+		chains = append(chains, chain)
+	}
+
+	if e.keepRaw {
+		add(-1)
+	}
+	for chain := range e.chains {
+		add(chain)
+	}
+	return chains
+}
+
+// encode runs payload through one chain; -1 returns it unchanged.
+func (e *payloadEncoder) encode(payload string, chain int) string {
+	if e == nil || chain < 0 {
+		return payload
+	}
+	for _, step := range e.chains[chain] {
+		payload = step(payload)
+	}
+	return payload
+}
+
+// isUnreserved reports whether b never needs escaping (RFC 3986 unreserved).
+func isUnreserved(b byte) bool {
+	return b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b >= '0' && b <= '9' ||
+		b == '-' || b == '_' || b == '.' || b == '~'
+}
+
+// urlEncode percent-encodes every byte outside the unreserved set, spaces
+// included (as %20, not +). Applying it twice gives double URL encoding.
+func urlEncode(payload string) string {
+	var b strings.Builder
+	for i := 0; i < len(payload); i++ {
+		c := payload[i]
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// unicodeEscape writes every character outside the unreserved set as a
+// JavaScript \uXXXX escape, using surrogate pairs above U+FFFF.
+func unicodeEscape(payload string) string {
+	var b strings.Builder
+	for _, r := range payload {
+		if r < 0x80 && isUnreserved(byte(r)) {
+			b.WriteRune(r)
+			continue
+		}
+		if r > 0xFFFF {
+			hi, lo := utf16.EncodeRune(r)
+			fmt.Fprintf(&b, "\\u%04x\\u%04x", hi, lo)
+			continue
+		}
+		fmt.Fprintf(&b, "\\u%04x", r)
+	}
+	return b.String()
+}
+
+// htmlEntities writes every character outside the unreserved set as a
+// numeric HTML entity, e.g. "<" becomes "&#60;".
+func htmlEntities(payload string) string {
+	var b strings.Builder
+	for _, r := range payload {
+		if r < 0x80 && isUnreserved(byte(r)) {
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteString("&#" + strconv.Itoa(int(r)) + ";")
+	}
+	return b.String()
+}
+
+// base64Encode is standard, padded base64.
+func base64Encode(payload string) string {
+	return base64.StdEncoding.EncodeToString([]byte(payload))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"html"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// maxExtractLineSize is the longest line the extractor accepts. Minified
+// JavaScript bundles routinely put megabytes on a single line.
+const maxExtractLineSize = 64 * 1024 * 1024
+
+var (
+	// Absolute http(s) URLs anywhere in the text
+	absoluteURLRe = regexp.MustCompile(`(?i)https?://[^\s"'<>` + "`" + `\\]+`)
+	// href/src style HTML attributes, quoted or not
+	attrURLRe = regexp.MustCompile(`(?i)\b(?:href|src|action|formaction|data-src|data-url)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	// Quoted JS strings that look like paths: /x, ./x, ../x, //host/x, a/b or file.ext
+	jsPathRe = regexp.MustCompile(`["'` + "`" + `]((?:\.{0,2}/|[A-Za-z0-9_\-.]+/)[^"'` + "`" + `\s<>{}]*|[A-Za-z0-9_\-/]+\.(?:php|asp|aspx|jsp|json|action|html?|js|cgi|do|xml)(?:\?[^"'` + "`" + `\s<>]*)?)["'` + "`" + `]`)
+)
+
+// mimePrefixes are JS strings such as "application/json" that look like
+// relative paths but never are.
+var mimePrefixes = []string{"application/", "text/", "image/", "audio/", "video/", "font/", "multipart/", "model/"}
+
+// newExtractReader returns a formatReader for --input-format extract. It finds
+// URLs embedded in free text, HTML attributes and JavaScript string literals.
+// Relative URLs are resolved against base; without a base they are skipped.
+// Each URL is passed on once per input file.
+func newExtractReader(base *url.URL) formatReader {
+	return func(r io.Reader, fn func(url string) error) error {
+		seen := make(map[string]bool)
+		emit := func(candidate string) error {
+			resolved, ok := resolveExtracted(candidate, base)
+			if !ok || seen[resolved] {
+				return nil
+			}
+			seen[resolved] = true
+			return fn(resolved)
+		}
+
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxExtractLineSize)
+		for scanner.Scan() {
+			// Undo JSON-style escaped slashes so https:\/\/x.com\/a is seen as a URL
+			line := strings.ReplaceAll(scanner.Text(), `\/`, "/")
+			for _, match := range absoluteURLRe.FindAllString(line, -1) {
+				if err := emit(match); err != nil {
+					return err
+				}
+			}
+			for _, groups := range attrURLRe.FindAllStringSubmatch(line, -1) {
+				// Only one of the quoted/unquoted groups is filled
+				value := groups[1] + groups[2] + groups[3]
+				if err := emit(html.UnescapeString(value)); err != nil {
+					return err
+				}
+			}
+			// Attributes were handled above; blank them so their quoted values aren't matched again as JS strings
+			for _, groups := range jsPathRe.FindAllStringSubmatch(attrURLRe.ReplaceAllString(line, " "), -1) {
+				if err := emit(groups[1]); err != nil {
+					return err
+				}
+			}
+		}
+		return scanner.Err()
+	}
+}
+
+// resolveExtracted cleans up a candidate found by the extractor and turns it
+// into an absolute http(s) URL. It reports false for anything that isn't one.
+func resolveExtracted(candidate string, base *url.URL) (string, bool) {
+	candidate = strings.TrimSpace(candidate)
+	candidate = strings.TrimRight(candidate, ".,;:)]}") // Trailing punctuation from prose
+	if candidate == "" || candidate == "/" || strings.HasPrefix(candidate, "#") {
+		return "", false
+	}
+
+	lower := strings.ToLower(candidate)
+	for _, prefix := range mimePrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return "", false
+		}
+	}
+	if strings.HasPrefix(lower, "//") && base == nil {
+		// Protocol-relative; assume https when there is nothing to inherit from
+		candidate = "https:" + candidate
+	}
+
+	ref, err := url.Parse(candidate)
+	if err != nil {
+		return "", false
+	}
+	if !ref.IsAbs() {
+		if base == nil {
+			return "", false
+		}
+		ref = base.ResolveReference(ref)
+	}
+	// Drops javascript:, mailto:, data: and friends
+	if ref.Scheme != "http" && ref.Scheme != "https" || ref.Host == "" {
+		return "", false
+	}
+	return ref.String(), true
+}
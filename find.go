@@ -3,53 +3,83 @@ package main
 import (
 	"bufio"
 	"fmt"
-	"os"
-	//"path/filepath"
+	"io"
 	"strings"
 )
 
-// processFind searches URLs for *any* of the given keywords.
-// It returns a map where keys are matching URLs and values are true.
-func processFind(urls []string, keywords string) map[string]bool {
-	if keywords == "" {
-		return make(map[string]bool) // Return empty map if no keywords
-	}
-	keywordList := parseKeywords(keywords)
-	matchingUrls := make(map[string]bool)
+// findResults streams URLs matching --find/--findX keywords into a result file.
+// The file is only created once the first matching URL arrives.
+type findResults struct {
+	keywords []string
+	matchAll bool // true for --findX (all keywords), false for --find (any keyword)
+	path     string
+	file     io.WriteCloser
+	writer   *bufio.Writer
+	count    int
+}
 
-	for _, url := range urls {
-		for _, keyword := range keywordList {
-			if strings.Contains(url, keyword) {
-				matchingUrls[url] = true
-				break // Move to the next URL once a keyword matches
-			}
-		}
+// newFindResults prepares a matcher for the given mode ("Find" or "FindX") and keywords.
+// It returns nil if no keywords were given.
+func newFindResults(mode string, keywords string, matchAll bool) *findResults {
+	keywordList := parseKeywords(keywords)
+	if len(keywordList) == 0 {
+		return nil
+	}
+	return &findResults{
+		keywords: keywordList,
+		matchAll: matchAll,
+		path:     generateOutputFileName(mode, keywords),
 	}
-	return matchingUrls
 }
 
-// processFindX searches URLs where *all* of the given keywords are present.
-// It returns a map where keys are matching URLs and values are true.
-func processFindX(urls []string, keywords string) map[string]bool {
-	if keywords == "" {
-		return make(map[string]bool) // Return empty map if no keywords
+// match reports whether url contains any (or, for --findX, all) of the keywords.
+func (f *findResults) match(url string) bool {
+	if f == nil {
+		return false
 	}
-	keywordList := parseKeywords(keywords)
-	matchingUrls := make(map[string]bool)
-
-	for _, url := range urls {
-		allMatch := true
-		for _, keyword := range keywordList {
+	if f.matchAll {
+		for _, keyword := range f.keywords {
 			if !strings.Contains(url, keyword) {
-				allMatch = false
-				break // Stop checking keywords for this URL if one doesn't match
+				return false // Stop checking keywords for this URL if one doesn't match
 			}
 		}
-		if allMatch {
-			matchingUrls[url] = true
+		return true
+	}
+	for _, keyword := range f.keywords {
+		if strings.Contains(url, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// save appends a matching URL to the result file, creating it on first use.
+func (f *findResults) save(url string) error {
+	if f.writer == nil {
+		file, err := createOutput(f.path)
+		if err != nil {
+			return fmt.Errorf("creating file '%s': %w", f.path, err)
 		}
+		f.file = file
+		f.writer = bufio.NewWriter(file)
+	}
+	if _, err := f.writer.WriteString(url + "\n"); err != nil {
+		return fmt.Errorf("writing to file '%s': %w", f.path, err)
+	}
+	f.count++
+	return nil
+}
+
+// close flushes and closes the result file if one was created.
+func (f *findResults) close() error {
+	if f == nil || f.file == nil {
+		return nil
 	}
-	return matchingUrls
+	if err := f.writer.Flush(); err != nil {
+		f.file.Close()
+		return fmt.Errorf("flushing file '%s': %w", f.path, err)
+	}
+	return f.file.Close()
 }
 
 // parseKeywords splits the keyword string by commas and trims spaces.
@@ -77,40 +107,3 @@ func generateOutputFileName(mode string, keywords string) string {
 
 	return fmt.Sprintf("%s-%s.txt", mode, safeKeywords)
 }
-
-// saveUrlsToFile writes the provided URLs (from the map keys) to the specified file.
-// It returns an error if writing fails.
-func saveUrlsToFile(filePath string, urlsToSave map[string]bool, quietMode bool) error {
-	if len(urlsToSave) == 0 {
-		if !quietMode {
-			fmt.Printf("%s[*] No URLs matched the criteria for file '%s'. File not created.%s\n", colorYellow, filePath, colorReset)
-		}
-		return nil // Not an error, just nothing to write
-	}
-
-	file, err := os.Create(filePath)
-	if err != nil {
-		return fmt.Errorf("creating file '%s': %w", filePath, err)
-	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	count := 0
-	for url := range urlsToSave {
-		_, err := writer.WriteString(url + "\n")
-		if err != nil {
-			// Try to flush before returning error to write partial data if possible
-			_ = writer.Flush()
-			return fmt.Errorf("writing to file '%s': %w", filePath, err)
-		}
-		count++
-	}
-	err = writer.Flush()
-	if err != nil {
-		return fmt.Errorf("flushing file '%s': %w", filePath, err)
-	}
-
-	// Success message even in quiet mode for file operations
-	fmt.Printf("%s[+] Successfully wrote %d URLs to %s%s\n", colorGreen+bold, count, filePath, colorReset)
-	return nil
-}
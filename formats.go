@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// formatReader pulls URLs out of one input stream and passes them to fn one at a time.
+type formatReader func(r io.Reader, fn func(url string) error) error
+
+// inputFormats lists the accepted --input-format names.
+var inputFormats = []string{"lines", "burp", "har", "extract"}
+
+// formatOptions holds the settings shared by the input format readers.
+type formatOptions struct {
+	mergePost bool     // fold url-encoded POST bodies (burp, har) into the query string
+	baseURL   *url.URL // resolves relative URLs found by extract; may be nil
+}
+
+// newFormatReader returns the reader for an --input-format name.
+func newFormatReader(format string, opts formatOptions) (formatReader, error) {
+	mergePost := opts.mergePost
+	switch format {
+	case "lines", "":
+		return scanReader, nil
+	case "extract":
+		return newExtractReader(opts.baseURL), nil
+	case "burp":
+		return func(r io.Reader, fn func(string) error) error {
+			return readBurpURLs(r, mergePost, fn)
+		}, nil
+	case "har":
+		return func(r io.Reader, fn func(string) error) error {
+			return readHarURLs(r, mergePost, fn)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown input format '%s' (use %s)", format, strings.Join(inputFormats, ", "))
+	}
+}
+
+// burpItem is one <item> of a Burp Suite "Save items" XML export.
+type burpItem struct {
+	URL     string `xml:"url"`
+	Method  string `xml:"method"`
+	Request struct {
+		Base64 bool   `xml:"base64,attr"`
+		Data   string `xml:",chardata"`
+	} `xml:"request"`
+}
+
+// readBurpURLs streams the request URLs out of a Burp XML export, one <item>
+// at a time, so large project exports never need to fit in memory.
+func readBurpURLs(r io.Reader, mergePost bool, fn func(url string) error) error {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("parsing Burp XML: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "item" {
+			continue
+		}
+
+		var item burpItem
+		if err := decoder.DecodeElement(&item, &start); err != nil {
+			return fmt.Errorf("parsing Burp XML: %w", err)
+		}
+		rawURL := strings.TrimSpace(item.URL)
+		if rawURL == "" {
+			continue
+		}
+		if mergePost && strings.EqualFold(strings.TrimSpace(item.Method), http.MethodPost) {
+			rawURL = mergeBurpBody(rawURL, item.Request.Data, item.Request.Base64)
+		}
+		if err := fn(rawURL); err != nil {
+			return err
+		}
+	}
+}
+
+// mergeBurpBody folds the form body of a raw Burp request into rawURL.
+// Requests that can't be decoded or aren't url-encoded forms are left alone.
+func mergeBurpBody(rawURL string, data string, isBase64 bool) string {
+	raw := []byte(data)
+	if isBase64 {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+		if err != nil {
+			return rawURL
+		}
+		raw = decoded
+	}
+	// Split at the blank line by hand: Burp's Content-Length may be missing
+	// (HTTP/2) or stale after editing, so the body is whatever follows it
+	head, body, found := strings.Cut(string(raw), "\r\n\r\n")
+	if !found {
+		head, body, found = strings.Cut(string(raw), "\n\n")
+		if !found {
+			return rawURL
+		}
+	}
+	contentType := ""
+	lines := strings.Split(strings.ReplaceAll(head, "\r\n", "\n"), "\n")
+	for _, line := range lines[1:] { // skip the request line
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Type") {
+			contentType = strings.TrimSpace(value)
+		}
+	}
+	if !isFormContentType(contentType) {
+		return rawURL
+	}
+	return mergeFormBody(rawURL, strings.TrimRight(body, "\r\n"))
+}
+
+// harEntry holds the parts of a HAR log entry that urlshort needs.
+type harEntry struct {
+	Request struct {
+		Method   string `json:"method"`
+		URL      string `json:"url"`
+		PostData *struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Params   []struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			} `json:"params"`
+		} `json:"postData"`
+	} `json:"request"`
+}
+
+// readHarURLs streams the request URLs out of a HAR capture. It walks the
+// JSON tokens down to log.entries and decodes one entry at a time.
+func readHarURLs(r io.Reader, mergePost bool, fn func(url string) error) error {
+	decoder := json.NewDecoder(r)
+	if err := enterJSONKey(decoder, "log"); err != nil {
+		return fmt.Errorf("parsing HAR: %w", err)
+	}
+	if err := enterJSONKey(decoder, "entries"); err != nil {
+		return fmt.Errorf("parsing HAR: %w", err)
+	}
+	if err := expectJSONDelim(decoder, '['); err != nil {
+		return fmt.Errorf("parsing HAR: %w", err)
+	}
+
+	for decoder.More() {
+		var entry harEntry
+		if err := decoder.Decode(&entry); err != nil {
+			return fmt.Errorf("parsing HAR: %w", err)
+		}
+		rawURL := strings.TrimSpace(entry.Request.URL)
+		if rawURL == "" {
+			continue
+		}
+		postData := entry.Request.PostData
+		if mergePost && postData != nil && strings.EqualFold(entry.Request.Method, http.MethodPost) && isFormContentType(postData.MimeType) {
+			body := postData.Text
+			if body == "" && len(postData.Params) > 0 {
+				// Some browsers only fill in params; rebuild the body from them
+				form := url.Values{}
+				for _, p := range postData.Params {
+					form.Add(p.Name, p.Value)
+				}
+				body = form.Encode()
+			}
+			rawURL = mergeFormBody(rawURL, body)
+		}
+		if err := fn(rawURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enterJSONKey expects an object next in the stream and skips its members
+// until it reaches key, leaving the decoder positioned at that key's value.
+func enterJSONKey(decoder *json.Decoder, key string) error {
+	if err := expectJSONDelim(decoder, '{'); err != nil {
+		return err
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if name, ok := token.(string); ok && name == key {
+			return nil
+		}
+		// Skip the value of any other member
+		var skip json.RawMessage
+		if err := decoder.Decode(&skip); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("missing \"%s\"", key)
+}
+
+// expectJSONDelim reads the next token and checks that it is the given delimiter.
+func expectJSONDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected '%s', found %v", delim, token)
+	}
+	return nil
+}
+
+// isFormContentType reports whether a Content-Type is a url-encoded form.
+func isFormContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/x-www-form-urlencoded"
+}
+
+// mergeFormBody appends the parameters of a url-encoded form body to the query
+// of rawURL, keeping their original encoding. Bodies that don't parse as a form
+// are ignored.
+func mergeFormBody(rawURL string, body string) string {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return rawURL
+	}
+	if _, err := url.ParseQuery(body); err != nil {
+		return rawURL
+	}
+
+	// Keep any fragment at the end
+	base, fragment, hasFragment := strings.Cut(rawURL, "#")
+	switch {
+	case !strings.Contains(base, "?"):
+		base += "?" + body
+	case strings.HasSuffix(base, "?"), strings.HasSuffix(base, "&"):
+		base += body
+	default:
+		base += "&" + body
+	}
+	if hasFragment {
+		base += "#" + fragment
+	}
+	return base
+}
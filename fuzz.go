@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"iter"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// defaultFuzzKeyword is the marker used by -w lists without an explicit
+// ":KEYWORD", and the one filled from -a/-F when no -w list claims it.
+const defaultFuzzKeyword = "FUZZ"
+
+// fuzzModes lists the --fuzz-mode values in the order they are documented.
+var fuzzModes = []string{"clusterbomb", "pitchfork", "sniper"}
+
+// fuzzWordlist is a wordlist bound to the marker it replaces.
+type fuzzWordlist struct {
+	keyword string
+	words   []string
+	chains  []int // --encode form of each word when the list is the -a/-F payloads; nil for -w lists
+}
+
+// fuzzer expands URLs that contain markers such as FUZZ or FUZZ2, ffuf style,
+// with each marker taking its words from its own wordlist.
+type fuzzer struct {
+	mode    string
+	lists   []fuzzWordlist  // longest keyword first, so FUZZ2 wins over FUZZ
+	encoder *payloadEncoder // --encode chains for the -a/-F payloads
+}
+
+// fuzzTemplate is a URL cut at its markers: literals[i] comes before the
+// marker in slot i, and the last literal follows the last marker.
+type fuzzTemplate struct {
+	literals []string
+	slots    []int // index into fuzzer.lists for each marker occurrence
+	used     []int // distinct lists in order of first appearance
+}
+
+// newFuzzer loads the -w wordlists. Each spec is "path" or "path:KEYWORD";
+// a bare path binds FUZZ. If no list binds FUZZ, the -a/-F payloads do, in
+// every --encode form, just as they would be injected into other URLs.
+// It returns nil when there are no markers to expand.
+func newFuzzer(specs []string, mode string, payloads []string, encoder *payloadEncoder) (*fuzzer, error) {
+	mode = strings.ToLower(mode)
+	if !slices.Contains(fuzzModes, mode) {
+		return nil, fmt.Errorf("unknown fuzz mode '%s' (use %s)", mode, strings.Join(fuzzModes, ", "))
+	}
+
+	f := &fuzzer{mode: mode, encoder: encoder}
+	bound := make(map[string]bool)
+	for _, spec := range specs {
+		path, keyword := spec, defaultFuzzKeyword
+		// Split at the last colon, but leave Windows drive letters ("C:\...") alone
+		if i := strings.LastIndex(spec, ":"); i > 1 {
+			path, keyword = spec[:i], spec[i+1:]
+		}
+		if keyword == "" {
+			return nil, fmt.Errorf("missing keyword in wordlist '%s'", spec)
+		}
+		if bound[keyword] {
+			return nil, fmt.Errorf("keyword '%s' is bound to more than one wordlist", keyword)
+		}
+		words, err := readLines(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading wordlist '%s': %w", path, err)
+		}
+		if len(words) == 0 {
+			return nil, fmt.Errorf("wordlist '%s' is empty", path)
+		}
+		bound[keyword] = true
+		f.lists = append(f.lists, fuzzWordlist{keyword: keyword, words: words})
+	}
+	if !bound[defaultFuzzKeyword] && len(payloads) > 0 {
+		list := fuzzWordlist{keyword: defaultFuzzKeyword}
+		for _, payload := range payloads {
+			for _, chain := range encoder.formChains(payload) {
+				list.words = append(list.words, payload)
+				list.chains = append(list.chains, chain)
+			}
+		}
+		f.lists = append(f.lists, list)
+	}
+	if len(f.lists) == 0 {
+		return nil, nil
+	}
+
+	sort.SliceStable(f.lists, func(i, j int) bool {
+		return len(f.lists[i].keyword) > len(f.lists[j].keyword)
+	})
+	return f, nil
+}
+
+// parse cuts rawURL at its markers. It returns nil if the URL has none.
+func (f *fuzzer) parse(rawURL string) *fuzzTemplate {
+	if f == nil {
+		return nil
+	}
+	t := &fuzzTemplate{}
+	seen := make(map[int]bool)
+	literal := strings.Builder{}
+	for i := 0; i < len(rawURL); {
+		matched := -1
+		for index, list := range f.lists {
+			if strings.HasPrefix(rawURL[i:], list.keyword) {
+				matched = index
+				break
+			}
+		}
+		if matched < 0 {
+			literal.WriteByte(rawURL[i])
+			i++
+			continue
+		}
+		t.literals = append(t.literals, literal.String())
+		literal.Reset()
+		t.slots = append(t.slots, matched)
+		if !seen[matched] {
+			seen[matched] = true
+			t.used = append(t.used, matched)
+		}
+		i += len(f.lists[matched].keyword)
+	}
+	if len(t.slots) == 0 {
+		return nil
+	}
+	t.literals = append(t.literals, literal.String())
+	return t
+}
+
+// expand yields every URL of a template according to the fuzz mode:
+//
+//	sniper       one marker at a time, the other markers left empty
+//	pitchfork    all markers in lockstep, stopping at the shortest wordlist
+//	clusterbomb  every combination of words (cartesian product)
+func (f *fuzzer) expand(t *fuzzTemplate, templater *payloadTemplater, in urlRecord) iter.Seq[pendingURL] {
+	return func(yield func(pendingURL) bool) {
+		ctx := newPayloadContext(in, in.URL)
+		picks := make([]int, len(f.lists)) // word index for each list; -1 leaves its markers empty
+		next := func() bool {
+			return yield(f.pending(t, picks, templater, ctx))
+		}
+		switch f.mode {
+		case "sniper":
+			for list := range picks {
+				picks[list] = -1
+			}
+			for _, list := range t.used {
+				for i := range f.lists[list].words {
+					picks[list] = i
+					if !next() {
+						return
+					}
+				}
+				picks[list] = -1
+			}
+		case "pitchfork":
+			shortest := -1
+			for _, list := range t.used {
+				if n := len(f.lists[list].words); shortest < 0 || n < shortest {
+					shortest = n
+				}
+			}
+			for i := 0; i < shortest; i++ {
+				for _, list := range t.used {
+					picks[list] = i
+				}
+				if !next() {
+					return
+				}
+			}
+		default: // clusterbomb
+			// Odometer over the used lists, so no combination is ever stored
+			for {
+				if !next() {
+					return
+				}
+				k := len(t.used) - 1
+				for ; k >= 0; k-- {
+					list := t.used[k]
+					picks[list]++
+					if picks[list] < len(f.lists[list].words) {
+						break
+					}
+					picks[list] = 0
+				}
+				if k < 0 {
+					return
+				}
+			}
+		}
+	}
+}
+
+// pending builds the URL for the picked words. Payload words go in in their
+// --encode form; if they have {{placeholders}}, fill builds the URL again with
+// them filled in for ctx, {{param}} being the marker they replace.
+func (f *fuzzer) pending(t *fuzzTemplate, picks []int, templater *payloadTemplater, ctx payloadContext) pendingURL {
+	values := make([]string, len(f.lists))
+	templated := -1
+	for _, list := range t.used {
+		i, l := picks[list], f.lists[list]
+		switch {
+		case i < 0:
+		case l.chains == nil:
+			values[list] = l.words[i]
+		default:
+			values[list] = f.encoder.encode(l.words[i], l.chains[i])
+			if templater.uses(l.words[i]) {
+				templated = list
+			}
+		}
+	}
+	p := pendingURL{url: t.build(values)}
+	if templated < 0 {
+		return p
+	}
+	l, i := f.lists[templated], picks[templated]
+	p.fill = func() string {
+		ctx.param = l.keyword
+		payload, canary := templater.expand(l.words[i], ctx)
+		values[templated] = f.encoder.encode(payload, l.chains[i])
+		finalURL := t.build(values)
+		templater.record(canary, ctx, finalURL)
+		return finalURL
+	}
+	return p
+}
+
+// build fills the template's markers with values (indexed like fuzzer.lists).
+func (t *fuzzTemplate) build(values []string) string {
+	var b strings.Builder
+	for i, slot := range t.slots {
+		b.WriteString(t.literals[i])
+		b.WriteString(values[slot])
+	}
+	b.WriteString(t.literals[len(t.literals)-1])
+	return b.String()
+}
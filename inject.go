@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// injectionPoint is one place in a URL where a payload can be put.
+type injectionPoint struct {
+	name  string // the parameter or path segment the payload lands in; empty when appending
+	apply func(payload string) string
+}
+
+// An injector finds the injection points of a generated variation. Payloads
+// from -a/-F are put into every point, giving one URL per (point, payload).
+type injector func(variation string) []injectionPoint
+
+// injectModes lists the --inject values in the order they are documented.
+var injectModes = []string{"append", "replace", "replace-all", "path", "path-append"}
+
+// newInjectors builds the injectors named in a comma separated --inject list.
+func newInjectors(names string) ([]injector, error) {
+	var injectors []injector
+	for _, name := range parseKeywords(names) {
+		switch strings.ToLower(name) {
+		case "append":
+			injectors = append(injectors, appendPoint)
+		case "replace":
+			injectors = append(injectors, paramValuePoints)
+		case "replace-all":
+			injectors = append(injectors, allParamValuesPoint)
+		case "path":
+			injectors = append(injectors, func(variation string) []injectionPoint {
+				return pathSegmentPoints(variation, false)
+			})
+		case "path-append":
+			injectors = append(injectors, func(variation string) []injectionPoint {
+				return pathSegmentPoints(variation, true)
+			})
+		default:
+			return nil, fmt.Errorf("unknown inject mode '%s' (use %s)", name, strings.Join(injectModes, ", "))
+		}
+	}
+	if len(injectors) == 0 {
+		return nil, fmt.Errorf("no inject mode selected (use %s)", strings.Join(injectModes, ", "))
+	}
+	return injectors, nil
+}
+
+// appendPoint is the original behaviour: the payload is concatenated to the
+// end of the whole variation, which lands it in the last query parameter.
+func appendPoint(variation string) []injectionPoint {
+	// Cheap string scan rather than a full parse: this runs for every variation
+	name := ""
+	if q := strings.LastIndex(variation, "?"); q >= 0 && !strings.Contains(variation[q:], "#") {
+		last := variation[q+1:]
+		if amp := strings.LastIndex(last, "&"); amp >= 0 {
+			last = last[amp+1:]
+		}
+		// A trailing "?" or "&" means the payload starts a new parameter of its own
+		name, _, _ = strings.Cut(last, "=")
+	}
+	return []injectionPoint{{
+		name:  name,
+		apply: func(payload string) string { return variation + payload },
+	}}
+}
+
+// paramValuePoints gives one point per query parameter, qsreplace style:
+// the payload replaces that parameter's value and the others are left alone.
+func paramValuePoints(variation string) []injectionPoint {
+	parts, ok := parseURLParts(variation)
+	if !ok || len(parts.params) == 0 {
+		return nil
+	}
+	points := make([]injectionPoint, len(parts.params))
+	for i, param := range parts.params {
+		points[i] = injectionPoint{
+			name: param.name,
+			apply: func(payload string) string {
+				params := append([]queryParam{}, parts.params...)
+				params[i].value, params[i].hasValue = payload, true
+				return parts.withParams(params)
+			},
+		}
+	}
+	return points
+}
+
+// allParamValuesPoint gives a single point that replaces every parameter's
+// value with the payload at once.
+func allParamValuesPoint(variation string) []injectionPoint {
+	parts, ok := parseURLParts(variation)
+	if !ok || len(parts.params) == 0 {
+		return nil
+	}
+	names := make([]string, len(parts.params))
+	for i, param := range parts.params {
+		names[i] = param.name
+	}
+	return []injectionPoint{{
+		name: strings.Join(names, ","),
+		apply: func(payload string) string {
+			params := append([]queryParam{}, parts.params...)
+			for i := range params {
+				params[i].value, params[i].hasValue = payload, true
+			}
+			return parts.withParams(params)
+		},
+	}}
+}
+
+// pathSegmentPoints gives one point per path segment. The payload replaces the
+// segment, or with keepSegment is appended to it; the rest of the path, the
+// query and the fragment stay as they were. "/api/v1/users" has three points.
+func pathSegmentPoints(variation string, keepSegment bool) []injectionPoint {
+	parts, ok := parseURLParts(variation)
+	if !ok {
+		return nil
+	}
+	segments := pathSegments(parts.path)
+	trailingSlash := strings.HasSuffix(parts.path, "/")
+	points := make([]injectionPoint, len(segments))
+	for i, segment := range segments {
+		points[i] = injectionPoint{
+			name: segment,
+			apply: func(payload string) string {
+				injected := append([]string{}, segments...)
+				if keepSegment {
+					injected[i] = segment + payload
+				} else {
+					injected[i] = payload
+				}
+				return parts.withPath(joinSegments(injected, trailingSlash))
+			},
+		}
+	}
+	return points
+}
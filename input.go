@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxLineSize is the longest single input line the scanner will accept.
+// bufio.Scanner defaults to 64KB, which long URLs from crawlers can exceed.
+const maxLineSize = 1024 * 1024
+
+// urlRecord is a URL together with the input it came from. It travels with
+// the URL through generation so the find and output stages know its origin.
+type urlRecord struct {
+	URL    string
+	Source string // input file path, or "stdin"
+}
+
+// urlSource streams input URLs to fn one at a time. It stops and returns the
+// first error returned by fn.
+type urlSource func(fn func(rec urlRecord) error) error
+
+// stringList is a flag.Value that collects every occurrence of a repeatable flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// expandInputs resolves the -f arguments into a list of files to read.
+// Each argument may be "-" (stdin), a plain file, a glob such as
+// "recon/*/urls.txt", or a directory, which is walked recursively.
+// A file matched by more than one argument is only read once.
+func expandInputs(args []string) ([]string, error) {
+	var paths []string
+	listed := make(map[string]bool)
+	addPath := func(path string) {
+		if !listed[path] {
+			listed[path] = true
+			paths = append(paths, path)
+		}
+	}
+	for _, arg := range args {
+		if arg == "-" {
+			addPath(arg)
+			continue
+		}
+
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			matches, err = filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("bad pattern '%s': %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match '%s'", arg)
+			}
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				addPath(filepath.Clean(match))
+				continue
+			}
+			// Walk directories in lexical order, picking up every regular file
+			err = filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.Type().IsRegular() {
+					addPath(path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return paths, nil
+}
+
+// stdinIsPiped reports whether stdin is a pipe or a redirected file rather than a terminal.
+func stdinIsPiped() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+// stdoutIsTerminal reports whether stdout is attached to a terminal.
+// When it isn't (e.g. `urlshort | httpx`), only URLs should be written to it.
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// openInput opens a file for reading. The path "-" means standard input.
+// gzip and zstd compressed input is detected by its magic bytes and
+// decompressed transparently.
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return decompressReader(os.Stdin, func() error { return nil })
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return decompressReader(file, file.Close)
+}
+
+// inputName returns a human readable name for an input path.
+func inputName(path string) string {
+	if path == "-" {
+		return "stdin"
+	}
+	return path
+}
+
+// scanLines calls fn for every non-empty, trimmed line of the file at path,
+// one line at a time, without loading the whole file into memory.
+func scanLines(path string, fn func(line string) error) error {
+	file, err := openInput(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return scanReader(file, fn)
+}
+
+// scanReader calls fn for every non-empty, trimmed line read from r.
+func scanReader(r io.Reader, fn func(line string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text()) // Trim whitespace
+		if line == "" {                           // Skip empty lines
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// fileSource returns a urlSource that reads each file in paths, in order,
+// using read to pull URLs out of it, and tags every URL with its file.
+func fileSource(paths []string, read formatReader) urlSource {
+	return func(fn func(rec urlRecord) error) error {
+		for _, path := range paths {
+			source := inputName(path)
+			file, err := openInput(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", source, err)
+			}
+			err = read(file, func(url string) error {
+				return fn(urlRecord{URL: url, Source: source})
+			})
+			file.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", source, err)
+			}
+		}
+		return nil
+	}
+}
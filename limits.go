@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// capReporter warns about input URLs whose variations were cut short by
+// --max-depth or --max-variations-per-url, so nothing is silently lost.
+type capReporter struct {
+	urls int    // input URLs that hit a limit
+	last string // the URL warned about last, so a URL hitting two limits counts once
+}
+
+// warn names rawURL and the limit it hit on stderr.
+func (r *capReporter) warn(rawURL, limit string) {
+	if r == nil {
+		return
+	}
+	if rawURL != r.last {
+		r.urls++
+		r.last = rawURL
+	}
+	fmt.Fprintf(os.Stderr, "%sWarning: %s hit %s, remaining variations skipped%s\n", colorYellow, rawURL, limit, colorReset)
+}
@@ -1,11 +1,11 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
+	"iter"
+	"net/url"
 	"os"
-	//"path/filepath" // Added for potential future path handling, used in find.go
 	"strings"
 )
 
@@ -25,19 +25,58 @@ const (
 
 func main() {
 	// Define command-line flags
-	inputFile := flag.String("f", "", "Input file containing URLs (required)")
-	outputFile := flag.String("o", "", "Output file to write shortened URLs")
+	var inputFiles stringList
+	flag.Var(&inputFiles, "f", "Input file, glob or directory containing URLs (repeatable; - for stdin; stdin is used automatically when piped)")
+	outputFile := flag.String("o", "", "Output file to write shortened URLs (.gz/.zst names are compressed)")
 	delimiters := flag.String("x", "=", "Delimiters to use for shortening (comma separated)")
+	backupPatternsFile := flag.String("backup-patterns", "", "File of backup name patterns for the backups strategy, one per line ({file} = config.php, {name} = config)")
+	paramWordlist := flag.String("param-wordlist", "", "Parameter names for the hidden-params strategy, one per line (seen endpoints use --dedup-mem)")
+	paramBatch := flag.Int("param-batch", 25, "Parameter names added per URL by the hidden-params strategy")
+	paramMap := flag.String("param-map", "", "JSON file recording which parameter batch and canary values went into which URL")
+	polluteValue := flag.String("pollute-value", "1337", "Value given to the duplicated parameters of the pollute strategy")
+	permuteSchemes := flag.String("permute-schemes", "http,https", "Schemes tried by the permute strategy (comma separated)")
+	permutePorts := flag.String("permute-ports", "8080,8443,3000", "Ports tried by the permute strategy (comma separated)")
+	hostPrefixesFile := flag.String("host-prefixes", "", "File of subdomain prefixes for the permute strategy, one per line (e.g. dev-, staging., api.)")
+	maxDepth := flag.Int("max-depth", 0, "How far split, ancestors and suffixes go back from the input URL: cuts per delimiter, parent directories, stripped segments (0 = no limit)")
+	maxVariations := flag.Int("max-variations-per-url", 0, "Maximum variations generated from one input URL, before payloads (0 = no limit)")
+	strategyList := flag.String("strategy", "split", "Variation strategies to run (comma separated): split (cut at -x delimiters), params (per URL component via net/url), ancestors (parent directories), suffixes (leading path segments stripped), backups (backup names of files), hidden-params (batches from --param-wordlist), pollute (parameter pollution and array syntax), bypass (403-bypass path forms), permute (other schemes, ports and hosts, fed to the other strategies), none (input URL only)")
+	sortMode := flag.String("sort", "input", "Output order: input (input order, each URL's variations shortest first), shortest-first, longest-first, lexical, host, interleave")
 	noDuplicates := flag.Bool("D", false, "Remove duplicate URLs")
+	dedupMem := flag.Int("dedup-mem", 64, "Memory in MB for the -D duplicate filter (fixed, does not grow with output)")
+	canonical := flag.Bool("canonical", false, "Compare -D duplicates in canonical form: lowercase scheme and host, no default port, sorted parameters, upper-case escapes")
+	dropFragment := flag.Bool("drop-fragment", false, "Ignore #fragments in the canonical form (implies --canonical)")
+	writeCanonical := flag.Bool("write-canonical", false, "Write the canonical form of each URL instead of the URL as generated (implies --canonical)")
+	smartDedup := flag.String("smart-dedup", "", "Keep one URL per pattern (same path and parameter names, ignoring values, numeric/UUID segments and static extensions): input, output or both")
 	quietMode := flag.Bool("Q", false, "Quiet mode (suppress banner and URL output to console, only show errors and final success message)")
 	splitPath := flag.Bool("p", false, "Split URLs at path segments (/)")
 	appendString := flag.String("a", "", "String to append to each generated variation")
 	appendFile := flag.String("F", "", "File containing strings to append (one per line, overrides -a)")
+	var encodeChains stringList
+	flag.Var(&encodeChains, "encode", "Encoding chain for -a/-F payloads, steps applied in order, e.g. url,url (repeatable; steps: url, unicode, html, base64)")
+	encodeRaw := flag.Bool("encode-raw", false, "Also emit the raw payload next to its --encode forms")
+	canaryMap := flag.String("canary-map", "", "File recording each {{canary}} with its parameter, input URL, the URL it was sent in and source (tab separated)")
+	var wordlists stringList
+	flag.Var(&wordlists, "w", "Wordlist for a FUZZ marker, as path or path:KEYWORD (repeatable, ffuf style)")
+	fuzzMode := flag.String("fuzz-mode", "clusterbomb", "How multiple FUZZ markers combine: clusterbomb (every combination), pitchfork (lockstep), sniper (one marker at a time)")
+	injectList := flag.String("inject", "append", "Where -a/-F payloads go (comma separated): append (end of URL), replace (each parameter value, one at a time), replace-all (all values at once), path (each path segment), path-append (end of each path segment)")
 	help := flag.Bool("h", false, "Show help message")
 
 	// --- New Flags ---
 	findKeywords := flag.String("find", "", "Keywords to find (comma separated). Matching URLs are highlighted green and saved to Find-<keywords>.txt")
 	findXKeywords := flag.String("findX", "", "Keywords that *all* must exist in URL (comma separated). Matching URLs are highlighted green and saved to FindX-<keywords>.txt")
+	inputFormat := flag.String("input-format", "lines", "Input format: lines (one URL per line), burp (Burp Suite XML export), har (HAR capture) or extract (find URLs in text, HTML and JS)")
+	mergePost := flag.Bool("merge-post", false, "Fold url-encoded POST bodies from burp/har input into the query string")
+	baseURL := flag.String("base-url", "", "Base URL used to resolve relative URLs found by --input-format extract")
+	validate := flag.Bool("validate", false, "Parse every input line as a URL and drop lines that aren't valid http(s) URLs")
+	normalize := flag.Bool("normalize", false, "Normalise input URLs: lowercase scheme and host, strip default ports, add --default-scheme when missing (implies --validate)")
+	defaultScheme := flag.String("default-scheme", "https", "Scheme added to input URLs without one when --normalize is set")
+	rejectedFile := flag.String("rejected", "", "File to write rejected input lines to, with the reason for each (implies --validate)")
+	filterNoise := flag.Bool("filter-noise", false, "Drop static assets (images, fonts, CSS, media) and analytics/tracking hosts from the input before generation")
+	noiseExts := flag.String("noise-exts", "", "Extensions dropped by --filter-noise, replacing the built-in list (comma separated, none = off; implies --filter-noise)")
+	noiseHostList := flag.String("noise-hosts", "", "Hosts dropped by --filter-noise with their subdomains, replacing the built-in list (comma separated, none = off; implies --filter-noise)")
+	allowExts := flag.String("allow-exts", "", "Allowlist mode: keep only URLs with these extensions or no extension (comma separated, none = extensionless only; implies --filter-noise)")
+	scopeFile := flag.String("scope", "", "Scope file of include/exclude rules (hosts, *.wildcards, CIDRs, path prefixes; ! excludes); out-of-scope input is skipped")
+	withSource := flag.Bool("with-source", false, "Add the input file each URL came from (tab separated) to console, -o and find output")
 	// --- End New Flags ---
 
 	// Set custom usage message
@@ -53,30 +92,47 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Keep stdout clean for the next tool in a pipeline
+	if !stdoutIsTerminal() {
+		msgOut = os.Stderr
+	}
+
 	// Show the banner only if not in quiet mode
 	if !*quietMode {
 		showBanner()
 	}
 
-	// Validate input: Input file is required
-	if *inputFile == "" {
-		// Print error to stderr
-		fmt.Fprintf(os.Stderr, "\n%sError: Input file (-f) is required.%s\n", colorRed+bold, colorReset)
-		showHelp() // Show help message on error
+	// Decide where the URLs come from: -f <file|glob|dir> (repeatable), -f - or a pipe on stdin
+	if len(inputFiles) == 0 {
+		if !stdinIsPiped() {
+			// Print error to stderr
+			fmt.Fprintf(os.Stderr, "\n%sError: Input file (-f) is required when nothing is piped to stdin.%s\n", colorRed+bold, colorReset)
+			showHelp() // Show help message on error
+			os.Exit(1)
+		}
+		inputFiles = stringList{"-"}
+	}
+	inputPaths, err := expandInputs(inputFiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError resolving input files: %v%s\n", colorRed+bold, err, colorReset)
 		os.Exit(1)
 	}
-
-	// Read input file
-	urls, err := readLines(*inputFile)
+	formatOpts := formatOptions{mergePost: *mergePost}
+	if *baseURL != "" {
+		formatOpts.baseURL, err = url.Parse(*baseURL)
+		if err != nil || !formatOpts.baseURL.IsAbs() {
+			fmt.Fprintf(os.Stderr, "%sError: --base-url must be an absolute URL, got '%s'%s\n", colorRed+bold, *baseURL, colorReset)
+			os.Exit(1)
+		}
+	}
+	readInput, err := newFormatReader(*inputFormat, formatOpts)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%sError reading input file '%s': %v%s\n", colorRed+bold, *inputFile, err, colorReset)
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", colorRed+bold, err, colorReset)
 		os.Exit(1)
 	}
-	if !*quietMode && len(urls) > 0 {
-		fmt.Printf("%s[*] Read %d URLs from %s%s\n", colorGreen, len(urls), *inputFile, colorReset)
-	} else if len(urls) == 0 {
-		fmt.Printf("%s[*] Input file '%s' is empty or contains no valid lines.%s\n", colorYellow, *inputFile, colorReset)
-		os.Exit(0) // Exit gracefully if input is empty
+	inputLabel := inputName(inputPaths[0])
+	if len(inputPaths) > 1 {
+		inputLabel = fmt.Sprintf("%d inputs", len(inputPaths))
 	}
 
 	// Read append file if specified
@@ -88,150 +144,270 @@ func main() {
 			os.Exit(1)
 		}
 		if !*quietMode && len(appendStrings) > 0 {
-			fmt.Printf("%s[*] Read %d strings to append from %s%s\n", colorGreen, len(appendStrings), *appendFile, colorReset)
+			fmt.Fprintf(msgOut, "%s[*] Read %d strings to append from %s%s\n", colorGreen, len(appendStrings), *appendFile, colorReset)
 		}
 	}
 
-	// Process URLs (Original Shortening/Variation Logic)
-	if !*quietMode {
-		fmt.Printf("%s[*] Processing URLs...%s\n", colorCyan, colorReset)
+	// Prepare --find/--findX matchers; their result files are written as matches stream in
+	find := newFindResults("Find", *findKeywords, false)
+	findX := newFindResults("FindX", *findXKeywords, true)
+	if find != nil && !*quietMode {
+		fmt.Fprintf(msgOut, "%s[*] Finding URLs containing any of: [%s]%s\n", colorCyan, *findKeywords, colorReset)
+	}
+	if findX != nil && !*quietMode {
+		fmt.Fprintf(msgOut, "%s[*] Finding URLs containing all of: [%s]%s\n", colorCyan, *findXKeywords, colorReset)
 	}
-	shortenedURLs := processURLs(urls, *delimiters, *noDuplicates, *splitPath, *appendString, appendStrings)
 
-	// --- Start New Find/FindX Processing ---
-	var foundUrlsMap map[string]bool
-	var foundXUrlsMap map[string]bool
-	var findOutputFile string
-	var findXOutputFile string
-	var findErr error // Use a separate error variable for find operations
+	// Validate (and optionally normalise) input lines before they reach the generator
+	source := fileSource(inputPaths, readInput)
+	var validator *urlValidator
+	if *validate || *normalize || *rejectedFile != "" {
+		validator = newURLValidator(*normalize, *defaultScheme, *rejectedFile)
+		source = validator.wrap(source)
+	}
 
-	// Process --find
-	if *findKeywords != "" {
+	// Drop out-of-scope assets before any variations are generated for them
+	var scope *scopeFilter
+	if *scopeFile != "" {
+		scope, err = loadScope(*scopeFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError reading scope file '%s': %v%s\n", colorRed+bold, *scopeFile, err, colorReset)
+			os.Exit(1)
+		}
 		if !*quietMode {
-			fmt.Printf("%s[*] Finding URLs containing any of: [%s]%s\n", colorCyan, *findKeywords, colorReset)
-		}
-		// Pass shortenedURLs to find functions
-		foundUrlsMap = processFind(shortenedURLs, *findKeywords)
-		if len(foundUrlsMap) > 0 {
-			findOutputFile = generateOutputFileName("Find", *findKeywords)
-			findErr = saveUrlsToFile(findOutputFile, foundUrlsMap, *quietMode) // Call function from find.go
-			if findErr != nil {
-				fmt.Fprintf(os.Stderr, "%sError saving --find results: %v%s\n", colorRed+bold, findErr, colorReset)
-				// Decide if you want to os.Exit(1) here or just continue
-			}
-		} else if !*quietMode {
-			fmt.Printf("%s[*] No URLs matched --find criteria.%s\n", colorYellow, colorReset)
+			fmt.Fprintf(msgOut, "%s[*] Loaded %d include and %d exclude scope rules from %s%s\n", colorGreen, len(scope.includes), len(scope.excludes), *scopeFile, colorReset)
 		}
-	} else {
-		foundUrlsMap = make(map[string]bool) // Initialize empty map if flag not used
+		source = scope.wrap(source)
 	}
 
-	// Process --findX
-	if *findXKeywords != "" {
-		if !*quietMode {
-			fmt.Printf("%s[*] Finding URLs containing all of: [%s]%s\n", colorCyan, *findXKeywords, colorReset)
-		}
-		// Pass shortenedURLs to find functions
-		foundXUrlsMap = processFindX(shortenedURLs, *findXKeywords)
-		if len(foundXUrlsMap) > 0 {
-			findXOutputFile = generateOutputFileName("FindX", *findXKeywords)
-			findErr = saveUrlsToFile(findXOutputFile, foundXUrlsMap, *quietMode) // Call function from find.go
-			if findErr != nil {
-				fmt.Fprintf(os.Stderr, "%sError saving --findX results: %v%s\n", colorRed+bold, findErr, colorReset)
-				// Decide if you want to os.Exit(1) here or just continue
-			}
-		} else if !*quietMode {
-			fmt.Printf("%s[*] No URLs matched --findX criteria.%s\n", colorYellow, colorReset)
-		}
-	} else {
-		foundXUrlsMap = make(map[string]bool) // Initialize empty map if flag not used
+	// Drop static assets and tracking beacons before generating anything for them
+	var noise *noiseFilter
+	if *filterNoise || *noiseExts != "" || *noiseHostList != "" || *allowExts != "" {
+		noise = newNoiseFilter(*noiseExts, *noiseHostList, *allowExts)
+		source = noise.wrap(source)
 	}
-	// --- End New Find/FindX Processing ---
 
-	// Output to console if not in quiet mode (MODIFIED LOOP)
+	// Collapse URLs that only differ in values, IDs or asset extensions
+	smartInput, smartOutput, err := parseSmartDedup(*smartDedup)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", colorRed+bold, err, colorReset)
+		os.Exit(1)
+	}
+	var inputPatterns, outputPatterns *patternFilter
+	if smartInput {
+		inputPatterns = newPatternFilter(*dedupMem)
+		source = inputPatterns.wrap(source)
+	}
+	if smartOutput {
+		outputPatterns = newPatternFilter(*dedupMem)
+	}
+
+	// Process URLs (Original Shortening/Variation Logic), one input URL at a time
 	if !*quietMode {
-		fmt.Printf("%s[*] Generated %d variations:%s\n", colorCyan, len(shortenedURLs), colorReset)
-		for _, url := range shortenedURLs {
-			// Check if the URL matched either find condition
-			isFindMatch := foundUrlsMap[url]  // Check if key exists (true if matched)
-			isFindXMatch := foundXUrlsMap[url] // Check if key exists (true if matched)
-
-			if isFindMatch || isFindXMatch {
-				// Print in green if it matched either
-				fmt.Printf("%s%s%s\n", colorGreen, url, colorReset)
-			} else {
-				// Print normally otherwise
-				fmt.Println(url)
-			}
+		fmt.Fprintf(msgOut, "%s[*] Processing URLs from %s...%s\n", colorCyan, inputLabel, colorReset)
+	}
+	var dedup *dedupFilter
+	if *noDuplicates {
+		dedup = newDedupFilter(*dedupMem)
+	}
+	cfg := processConfig{
+		strategyList:   *strategyList,
+		delimiters:     *delimiters,
+		splitPath:      *splitPath,
+		maxVariations:  *maxVariations,
+		injectList:     *injectList,
+		appendString:   *appendString,
+		appendStrings:  appendStrings,
+		encodeChains:   encodeChains,
+		encodeRaw:      *encodeRaw,
+		canaryMap:      *canaryMap,
+		wordlists:      wordlists,
+		fuzzMode:       *fuzzMode,
+		dedup:          dedup,
+		patterns:       outputPatterns,
+		canonical:      *canonical || *dropFragment || *writeCanonical,
+		dropFragment:   *dropFragment,
+		writeCanonical: *writeCanonical,
+		strategy: strategyConfig{
+			maxDepth:       *maxDepth,
+			polluteValue:   *polluteValue,
+			permuteSchemes: *permuteSchemes,
+			permutePorts:   *permutePorts,
+		},
+	}
+	if *hostPrefixesFile != "" {
+		cfg.strategy.hostPrefixes, err = readLines(*hostPrefixesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError reading host prefixes file '%s': %v%s\n", colorRed+bold, *hostPrefixesFile, err, colorReset)
+			os.Exit(1)
 		}
 	}
-
-	// Write to output file if specified (using the ORIGINAL shortenedURLs list)
-	// Note: The --o flag saves *all* generated URLs, not just the found ones.
-	if *outputFile != "" {
-		err = writeLines(*outputFile, shortenedURLs) // Use original writeLines
+	if *backupPatternsFile != "" {
+		cfg.strategy.backupPatterns, err = readLines(*backupPatternsFile)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "%sError writing to output file '%s': %v%s\n", colorRed+bold, *outputFile, err, colorReset)
-			os.Exit(1) // Exit on primary output file error
+			fmt.Fprintf(os.Stderr, "%sError reading backup patterns file '%s': %v%s\n", colorRed+bold, *backupPatternsFile, err, colorReset)
+			os.Exit(1)
+		}
+	}
+	if *paramWordlist != "" {
+		names, err := readLines(*paramWordlist)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError reading parameter wordlist '%s': %v%s\n", colorRed+bold, *paramWordlist, err, colorReset)
+			os.Exit(1)
+		}
+		if !*quietMode && len(names) > 0 {
+			fmt.Fprintf(msgOut, "%s[*] Read %d parameter names from %s%s\n", colorGreen, len(names), *paramWordlist, colorReset)
+		}
+		cfg.strategy.discovery, err = newParamDiscovery(names, *paramBatch, *paramMap, *dedupMem)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: %v%s\n", colorRed+bold, err, colorReset)
+			os.Exit(1)
+		}
+	}
+	opts, err := newProcessOptions(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", colorRed+bold, err, colorReset)
+		os.Exit(1)
+	}
+	sorter, err := newOutputSorter(*sortMode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", colorRed+bold, err, colorReset)
+		os.Exit(1)
+	}
+
+	// Only now that every flag has been checked and every file read can -o be
+	// created, so a typo never truncates the previous output
+	sink, err := newOutputSink(*outputFile, *quietMode, *withSource, find, findX)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError creating output file '%s': %v%s\n", colorRed+bold, *outputFile, err, colorReset)
+		os.Exit(1)
+	}
+	emit := sink.write
+	if sorter != nil {
+		// Global orders need the whole output before the first URL can be written
+		emit = sorter.write
+	}
+	inputCount, err := processURLs(source, opts, emit)
+	if err == nil && sorter != nil {
+		err = sorter.flush(sink.write)
+	}
+	closeErr := sink.close()
+	if err := opts.templater.close(); err != nil && closeErr == nil {
+		closeErr = err
+	}
+	if err := cfg.strategy.discovery.close(); err != nil && closeErr == nil {
+		closeErr = err
+	}
+	if validator != nil {
+		if err := validator.close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError processing input: %v%s\n", colorRed+bold, err, colorReset)
+		os.Exit(1)
+	}
+	if closeErr != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", colorRed+bold, closeErr, colorReset)
+		os.Exit(1) // Exit on output file error
+	}
+	if validator != nil && validator.rejected > 0 {
+		reportMsg := ""
+		if *rejectedFile != "" {
+			reportMsg = fmt.Sprintf(" (see %s)", *rejectedFile)
+		}
+		fmt.Fprintf(msgOut, "%s[*] Rejected %d invalid input lines%s%s\n", colorYellow, validator.rejected, reportMsg, colorReset)
+	}
+	if scope != nil && scope.dropped > 0 {
+		fmt.Fprintf(msgOut, "%s[*] Skipped %d out-of-scope URLs%s\n", colorYellow, scope.dropped, colorReset)
+	}
+	if opts.caps.urls > 0 {
+		fmt.Fprintf(msgOut, "%s[*] %d input URLs hit --max-depth or --max-variations-per-url (see warnings above)%s\n", colorYellow, opts.caps.urls, colorReset)
+	}
+	if noise != nil && noise.total > 0 {
+		fmt.Fprintf(msgOut, "%s[*] Dropped %d noise URLs before generation: %s%s\n", colorYellow, noise.total, noise.summary(), colorReset)
+	}
+	if inputPatterns != nil && inputPatterns.dropped > 0 {
+		fmt.Fprintf(msgOut, "%s[*] Smart dedup collapsed %d input URLs into an earlier URL of the same pattern%s\n", colorYellow, inputPatterns.dropped, colorReset)
+	}
+	if outputPatterns != nil && outputPatterns.dropped > 0 {
+		fmt.Fprintf(msgOut, "%s[*] Smart dedup collapsed %d generated URLs into an earlier URL of the same pattern%s\n", colorYellow, outputPatterns.dropped, colorReset)
+	}
+	if inputCount == 0 {
+		fmt.Fprintf(msgOut, "%s[*] Input from %s is empty or contains no valid lines.%s\n", colorYellow, inputLabel, colorReset)
+		os.Exit(0) // Exit gracefully if input is empty
+	}
+
+	// Report saved --find/--findX results (shown even in quiet mode, like other file operations)
+	for _, f := range []*findResults{find, findX} {
+		if f == nil {
+			continue
+		}
+		if f.count > 0 {
+			fmt.Fprintf(msgOut, "%s[+] Successfully wrote %d URLs to %s%s\n", colorGreen+bold, f.count, f.path, colorReset)
+		} else if !*quietMode {
+			fmt.Fprintf(msgOut, "%s[*] No URLs matched the criteria for file '%s'. File not created.%s\n", colorYellow, f.path, colorReset)
 		}
+	}
+
+	if *outputFile != "" {
 		// Success message shown even in quiet mode if output file is used
-		fmt.Printf("%s[+] Successfully wrote %d URLs to %s%s\n", colorGreen+bold, len(shortenedURLs), *outputFile, colorReset)
+		fmt.Fprintf(msgOut, "%s[+] Successfully wrote %d URLs to %s%s\n", colorGreen+bold, sink.count, *outputFile, colorReset)
 	} else if *quietMode {
 		// Adjusted quiet message to mention find results if any were saved
 		findMsg := ""
-		if findOutputFile != "" {
-			findMsg += fmt.Sprintf(" Saved --find results to %s.", findOutputFile)
+		if find != nil && find.count > 0 {
+			findMsg += fmt.Sprintf(" Saved --find results to %s.", find.path)
 		}
-		if findXOutputFile != "" {
-			findMsg += fmt.Sprintf(" Saved --findX results to %s.", findXOutputFile)
+		if findX != nil && findX.count > 0 {
+			findMsg += fmt.Sprintf(" Saved --findX results to %s.", findX.path)
 		}
-		if findMsg == "" && (*findKeywords != "" || *findXKeywords != "") {
+		if findMsg == "" && (find != nil || findX != nil) {
 			findMsg = " No matching URLs found for --find/--findX."
 		}
 
-		fmt.Printf("%s[+] Processing complete. %d variations generated (output suppressed).%s%s\n", colorGreen+bold, len(shortenedURLs), findMsg, colorReset) // adjusted message
-	} else if len(shortenedURLs) > 0 && !*quietMode {
-		// Indicate console output done
-		fmt.Printf("%s[+] Output displayed above.%s\n", colorGreen+bold, colorReset)
+		fmt.Fprintf(msgOut, "%s[+] Processing complete. %d variations generated from %d URLs (output suppressed).%s%s\n", colorGreen+bold, sink.count, inputCount, findMsg, colorReset)
+	} else {
+		fmt.Fprintf(msgOut, "%s[+] Generated %d variations from %d URLs.%s\n", colorGreen+bold, sink.count, inputCount, colorReset)
 	}
 }
 
 // Displays the application banner
 func showBanner() {
 	// Top border
-	fmt.Printf("%s╔════════════════════════════════════════════════════════════════════════════════════════════════════╗%s\n", colorWhite, colorReset)
+	fmt.Fprintf(msgOut, "%s╔════════════════════════════════════════════════════════════════════════════════════════════════════╗%s\n", colorWhite, colorReset)
 
 	// Logo lines (6 lines)
-	fmt.Printf("%s║%s  ██╗  ██╗██████╗ ██╗      ███████╗██╗  ██╗ ██████╗ ██████╗ ████████╗                               %s║%s\n", colorWhite, bold+colorRed, colorReset+colorWhite, colorReset)
-	fmt.Printf("%s║%s  ██║  ██║██╔══██╗██║      ██╔════╝██║  ██║██╔═══██╗██╔══██╗╚══██╔══╝                               %s║%s\n", colorWhite, bold+colorRed, colorReset+colorWhite, colorReset)
-	fmt.Printf("%s║%s  ██║  ██║██████╔╝██║█████╗███████╗███████║██║   ██║██████╔╝   ██║                                  %s║%s\n", colorWhite, bold+colorRed, colorReset+colorWhite, colorReset)
-	fmt.Printf("%s║%s  ██║  ██║██╔══██╗██║╚════╝╚════██║██╔══██║██║   ██║██╔══██╗   ██║                                  %s║%s\n", colorWhite, bold+colorRed, colorReset+colorWhite, colorReset)
-	fmt.Printf("%s║%s  ╚██████╔╝██║  ██║███████╗███████║██║  ██║╚██████╔╝██║  ██║   ██║                                  %s║%s\n", colorWhite, bold+colorRed, colorReset+colorWhite, colorReset)
-	fmt.Printf("%s║%s   ╚═════╝ ╚═╝  ╚═╝╚══════╝╚══════╝╚═╝  ╚═╝ ╚═════╝ ╚═╝  ╚═╝   ╚═╝                                  %s║%s\n", colorWhite, bold+colorRed, colorReset+colorWhite, colorReset)
+	fmt.Fprintf(msgOut, "%s║%s  ██╗  ██╗██████╗ ██╗      ███████╗██╗  ██╗ ██████╗ ██████╗ ████████╗                               %s║%s\n", colorWhite, bold+colorRed, colorReset+colorWhite, colorReset)
+	fmt.Fprintf(msgOut, "%s║%s  ██║  ██║██╔══██╗██║      ██╔════╝██║  ██║██╔═══██╗██╔══██╗╚══██╔══╝                               %s║%s\n", colorWhite, bold+colorRed, colorReset+colorWhite, colorReset)
+	fmt.Fprintf(msgOut, "%s║%s  ██║  ██║██████╔╝██║█████╗███████╗███████║██║   ██║██████╔╝   ██║                                  %s║%s\n", colorWhite, bold+colorRed, colorReset+colorWhite, colorReset)
+	fmt.Fprintf(msgOut, "%s║%s  ██║  ██║██╔══██╗██║╚════╝╚════██║██╔══██║██║   ██║██╔══██╗   ██║                                  %s║%s\n", colorWhite, bold+colorRed, colorReset+colorWhite, colorReset)
+	fmt.Fprintf(msgOut, "%s║%s  ╚██████╔╝██║  ██║███████╗███████║██║  ██║╚██████╔╝██║  ██║   ██║                                  %s║%s\n", colorWhite, bold+colorRed, colorReset+colorWhite, colorReset)
+	fmt.Fprintf(msgOut, "%s║%s   ╚═════╝ ╚═╝  ╚═╝╚══════╝╚══════╝╚═╝  ╚═╝ ╚═════╝ ╚═╝  ╚═╝   ╚═╝                                  %s║%s\n", colorWhite, bold+colorRed, colorReset+colorWhite, colorReset)
 
 	// Separator
-	fmt.Printf("%s╠════════════════════════════════════════════════════════════════════════════════════════════════════╣%s\n", colorWhite, colorReset)
+	fmt.Fprintf(msgOut, "%s╠════════════════════════════════════════════════════════════════════════════════════════════════════╣%s\n", colorWhite, colorReset)
 
 	// Tool Type line
-	fmt.Printf("%s║ %s💡 Tool Type:%s %sAdvanced URL Shortener & Parameter Generator%s                                         %s║%s\n",
+	fmt.Fprintf(msgOut, "%s║ %s💡 Tool Type:%s %sAdvanced URL Shortener & Parameter Generator%s                                         %s║%s\n",
 		colorWhite, colorCyan+bold, colorReset, colorCyan+italic, colorReset, colorWhite, colorReset)
 
 	// Use Case line
-	fmt.Printf("%s║ %s💡 Use Case:%s  %sSecurity Testing • Web Dev Utility • Payload Injector%s                                %s║%s\n",
+	fmt.Fprintf(msgOut, "%s║ %s💡 Use Case:%s  %sSecurity Testing • Web Dev Utility • Payload Injector%s                                %s║%s\n",
 		colorWhite, colorYellow+bold, colorReset, colorYellow+italic, colorReset, colorWhite, colorReset)
 
 	// Separator
-	fmt.Printf("%s╠════════════════════════════════════════════════════════════════════════════════════════════════════╣%s\n", colorWhite, colorReset)
+	fmt.Fprintf(msgOut, "%s╠════════════════════════════════════════════════════════════════════════════════════════════════════╣%s\n", colorWhite, colorReset)
 
 	// Developer/Version/License line
-	fmt.Printf("%s║ %s👾 Developed by:%s %sTeam HyperGod-X%s   %s📦 Version:%s %s1.1.0%s   %s📝 License:%s %sMIT%s                             %s║%s\n", // Consider bumping version
+	fmt.Fprintf(msgOut, "%s║ %s👾 Developed by:%s %sTeam HyperGod-X%s   %s📦 Version:%s %s1.1.0%s   %s📝 License:%s %sMIT%s                             %s║%s\n", // Consider bumping version
 		colorWhite, colorPurple+bold, colorReset, colorPurple+italic, colorReset,
 		colorBlue+bold, colorReset, colorBlue+bold, colorReset, // Updated Version to 1.1.0
 		colorGreen+bold, colorReset, colorGreen+bold, colorReset,
 		colorWhite, colorReset)
 
 	// Bottom border
-	fmt.Printf("%s╚════════════════════════════════════════════════════════════════════════════════════════════════════╝%s\n", colorWhite, colorReset)
+	fmt.Fprintf(msgOut, "%s╚════════════════════════════════════════════════════════════════════════════════════════════════════╝%s\n", colorWhite, colorReset)
 }
 
 // Displays the help message for command-line arguments (UPDATED)
@@ -239,68 +415,189 @@ func showHelp() {
 	// Ensure help format aligns with flags
 	fmt.Printf("\n%sUsage:%s\n", bold, colorReset)
 	fmt.Println("  urlshort -f <input-file> [options]")
+	fmt.Println("  <command> | urlshort [options]")
 	fmt.Printf("\n%sOptions:%s\n", bold, colorReset) // Use Printf for colors here too
-	fmt.Println("  -f string     Input file containing URLs (required)")
-	fmt.Println("  -o string     Output file to write shortened URLs")
+	fmt.Println("  -f string     Input file, glob or directory containing URLs; repeatable (use - for stdin; read from stdin automatically when piped)")
+	fmt.Println("                gzip and zstd compressed input is detected and decompressed automatically")
+	fmt.Println("  --input-format string Input format: lines, burp (Burp Suite \"Save items\" XML), har (HAR capture)")
+	fmt.Println("                or extract (pull URLs out of free text, HTML and JavaScript) (default \"lines\")")
+	fmt.Println("  --merge-post  Fold url-encoded POST bodies from burp/har input into the query string")
+	fmt.Println("  --base-url string Resolve relative URLs found by extract against this URL (relative URLs are skipped without it)")
+	fmt.Println("  --validate    Parse every input line as a URL and drop lines that aren't valid http(s) URLs")
+	fmt.Println("  --normalize   Lowercase scheme and host, strip default ports and add a missing scheme (implies --validate)")
+	fmt.Println("  --default-scheme string Scheme added by --normalize to lines without one (default \"https\")")
+	fmt.Println("  --rejected string File to write rejected input lines to, with a reason for each (implies --validate)")
+	fmt.Println("  --scope string Scope file: one rule per line (example.com, *.example.com, 10.0.0.0/8, example.com/api, /admin);")
+	fmt.Println("                lines starting with ! exclude, # are comments. Out-of-scope input URLs are skipped before generation")
+	fmt.Println("  --filter-noise Drop static assets (images, fonts, CSS, media) and analytics/tracking hosts from the input")
+	fmt.Println("                before generation; a summary line shows what was dropped and why")
+	fmt.Println("  --noise-exts string Extensions to drop instead of the built-in list (comma separated, none = off)")
+	fmt.Println("  --noise-hosts string Hosts to drop, with their subdomains, instead of the built-in list (comma separated, none = off)")
+	fmt.Println("  --allow-exts string Allowlist mode: keep only URLs with these extensions or no extension at all")
+	fmt.Println("                (none = only URLs without an extension)")
+	fmt.Println("                The three lists imply --filter-noise")
+	fmt.Println("  -o string     Output file to write shortened URLs (compressed when the name ends in .gz or .zst)")
 	fmt.Println("  -x string     Delimiters to use for splitting parameters (comma separated, e.g., \"&,=\") (default \"=\")")
 	fmt.Println("  -p            Split URLs at path segments (/) as well")
+	fmt.Println("  --strategy string Variation strategies, comma separated (default \"split\"):")
+	fmt.Println("                  split      cut the raw URL at the -x delimiters (and / with -p)")
+	fmt.Println("                  params     parse with net/url: each parameter alone, each parameter removed, no query, each path ancestor")
+	fmt.Println("                  ancestors  every parent directory of the path, without the query (/a/b/c.php -> /a/b/, /a/, /)")
+	fmt.Println("                  suffixes   leading path segments stripped one at a time, query kept (/a/b/c.php -> /b/c.php, /c.php)")
+	fmt.Println("                  backups    backup and editor copies of a file at the end of the path (config.php.bak, config.old, .config.php.swp, ...)")
+	fmt.Println("                  hidden-params  Arjun style: --param-wordlist names added in batches to each unique endpoint,")
+	fmt.Println("                             each with a random canary value")
+	fmt.Println("                  pollute    per parameter: duplicated (a=1&a=1337), duplicated after ; (a=1;a=1337), a[]=, a[0]=,")
+	fmt.Println("                             a.x=, and the whole query joined with semicolons")
+	fmt.Println("                  bypass     403-bypass forms of the last path segment: /./admin, //admin, /%2e/admin,")
+	fmt.Println("                             /admin..;/, /admin;/, /ADMIN, /admin%20, /admin.json")
+	fmt.Println("                  permute    the URL on every --permute-schemes scheme, --permute-ports port and --host-prefixes")
+	fmt.Println("                             host; the other strategies then run on each permutation too")
+	fmt.Println("                  none       only the input URL itself")
+	fmt.Println("  --backup-patterns string File of patterns for backups, one per line, replacing the built-in list;")
+	fmt.Println("                {file} is the file name (config.php), {name} the name without extension (config)")
+	fmt.Println("  --param-wordlist string Parameter names for hidden-params, one per line")
+	fmt.Println("  --param-batch int Parameter names per URL for hidden-params (default 25)")
+	fmt.Println("  --param-map string JSON file recording each hidden-params URL with its endpoint, batch and canary values")
+	fmt.Println("  --pollute-value string Value of the duplicated parameters added by pollute (default \"1337\")")
+	fmt.Println("  --permute-schemes string Schemes tried by permute, comma separated (default \"http,https\")")
+	fmt.Println("  --permute-ports string Ports tried by permute, comma separated (default \"8080,8443,3000\")")
+	fmt.Println("  --host-prefixes string File of subdomain prefixes for permute, one per line (dev-, staging., api.)")
+	fmt.Println("  --max-depth int How far split, ancestors and suffixes go back from the input URL (0 = no limit):")
+	fmt.Println("                the last N cuts of each delimiter, the N nearest parent directories, N stripped segments")
+	fmt.Println("  --max-variations-per-url int Cap on variations from one input URL, before payloads (0 = no limit)")
+	fmt.Println("                URLs that hit either limit are named in a warning on stderr")
+	fmt.Println("  --sort string Output order (default \"input\"):")
+	fmt.Println("                  input           input order, each URL's variations shortest first (streams as it goes)")
+	fmt.Println("                  shortest-first  all output by length, shortest first")
+	fmt.Println("                  longest-first   all output by length, longest first")
+	fmt.Println("                  lexical         all output sorted as strings")
+	fmt.Println("                  host            grouped by host, hosts in alphabetical order")
+	fmt.Println("                  interleave      round-robin across hosts, so consecutive URLs hit different hosts")
+	fmt.Println("                Every order but input holds the whole output in memory until the input is read")
 	fmt.Println("  -a string     String to append to each generated variation")
 	fmt.Println("  -F string     File containing strings to append (one per line, overrides -a)")
+	fmt.Println("  --inject string Where -a/-F payloads go, comma separated (default \"append\"):")
+	fmt.Println("                  append       concatenate to the end of each variation")
+	fmt.Println("                  replace      replace each query parameter value in turn (one URL per parameter and payload)")
+	fmt.Println("                  replace-all  replace every query parameter value at once (one URL per payload)")
+	fmt.Println("                  path         replace each path segment in turn, keeping the rest of the URL and the query")
+	fmt.Println("                  path-append  append to each path segment in turn")
+	fmt.Println("                Payloads may use {{host}}, {{path}}, {{param}}, {{index}}, {{rand}} and {{canary}}, filled in per generated URL")
+	fmt.Println("  --canary-map string File recording each {{canary}} with its parameter, input URL, the URL it was sent in and source (TSV)")
+	fmt.Println("  --encode string Encode -a/-F payloads before injecting; comma separated steps applied in order (url,url =")
+	fmt.Println("                double URL encoding). Repeat for several forms of each payload. Steps: url, unicode (\\uXXXX),")
+	fmt.Println("                html (&#NN;), base64")
+	fmt.Println("  --encode-raw  Also emit the raw payload next to the encoded forms")
+	fmt.Println("  -w string     Wordlist for a marker in template URLs, as path or path:KEYWORD; repeatable (ffuf style).")
+	fmt.Println("                Input URLs containing a marker (e.g. https://x.com/api/FUZZ?id=FUZZ2) are expanded instead of")
+	fmt.Println("                shortened. FUZZ falls back to the -a/-F payloads (with --encode) when no -w list names it")
+	fmt.Println("  --fuzz-mode string How multiple markers combine (default \"clusterbomb\"):")
+	fmt.Println("                  clusterbomb  every combination of words")
+	fmt.Println("                  pitchfork    markers advance in lockstep, stopping at the shortest wordlist")
+	fmt.Println("                  sniper       one marker at a time, the other markers left empty")
 	fmt.Println("  -D            Remove duplicate generated URLs")
+	fmt.Println("  --dedup-mem int Memory in MB for the -D duplicate filter; fixed size, very rarely drops a unique URL (default 64)")
+	fmt.Println("  --canonical   Compare -D duplicates in canonical form: lowercase scheme and host, default ports dropped,")
+	fmt.Println("                \"/\" for an empty path, parameters sorted by name, percent-escapes in upper case")
+	fmt.Println("  --drop-fragment Ignore #fragments in the canonical form (implies --canonical)")
+	fmt.Println("  --write-canonical Write the canonical form of each URL instead of the URL as generated (implies --canonical)")
+	fmt.Println("  --smart-dedup string Keep one URL per pattern, uro style: URLs differing only in parameter values, numeric or")
+	fmt.Println("                UUID path segments or static asset extensions are collapsed. Stage: input (before generation),")
+	fmt.Println("                output (generated URLs) or both. Uses --dedup-mem per stage")
 	fmt.Println("  -Q            Quiet mode (suppress banner and URL output to console, only show errors and final success message)")
 	// --- Additions for Find/FindX ---
 	fmt.Println("  --find string Keywords to find (comma separated). Highlights matches and saves to Find-<keywords>.txt")
 	fmt.Println("  --findX string Keywords where *all* must exist in URL (comma separated). Highlights matches and saves to FindX-<keywords>.txt")
 	// --- End Additions ---
+	fmt.Println("  --with-source Add the source input file to each output line (tab separated)")
 	fmt.Println("  -h            Show this help message")
 	fmt.Printf("\n%sExamples:%s\n", bold, colorReset) // Use Printf for colors
 	fmt.Println("  urlshort -f urls.txt -o shortened.txt -x \"&,=\" -p -F payloads.txt -D")
-	fmt.Println("  urlshort -f urls.txt --find \"wp-json,api\"") // Added example for find
+	fmt.Println("  urlshort -f urls.txt --find \"wp-json,api\"")                // Added example for find
 	fmt.Println("  urlshort -f urls.txt --findX \"user,token\" -o results.txt") // Added example for findX
+	fmt.Println("  gau example.com | urlshort -x \"&,=\" -D | httpx")
+	fmt.Println("  urlshort -f 'recon/*/urls.txt' -f extra/ --find admin --with-source")
+	fmt.Println("  urlshort -f archive/urls.txt.zst -D -o variations.txt.gz")
+	fmt.Println("  urlshort -f burp-items.xml --input-format burp --merge-post -x \"&,=\"")
+	fmt.Println("  urlshort -f app.js --input-format extract --base-url https://example.com/")
+	fmt.Println("  urlshort -f messy.txt --normalize --rejected rejected.tsv -o out.txt")
+	fmt.Println("  urlshort -f urls.txt --scope scope.txt -x \"&,=\" -D")
+	fmt.Println("  urlshort -f urls.txt -x \"&,=\" -D --canonical --drop-fragment")
+	fmt.Println("  gau example.com | urlshort --filter-noise --noise-hosts none -x \"&,=\" -D")
+	fmt.Println("  urlshort -f urls.txt --allow-exts php,aspx,jsp,json -x \"&,=\"")
+	fmt.Println("  gau example.com | urlshort --smart-dedup input -x \"&,=\" -D")
+	fmt.Println("  urlshort -f urls.txt --strategy split,params -x \"&,=\" -D")
+	fmt.Println("  urlshort -f urls.txt --strategy ancestors,suffixes -D | httpx -mc 200,403")
+	fmt.Println("  urlshort -f urls.txt --strategy backups --backup-patterns backups.txt -D")
+	fmt.Println("  urlshort -f urls.txt --strategy hidden-params --param-wordlist params.txt --param-batch 30 --param-map batches.json")
+	fmt.Println("  urlshort -f urls.txt --strategy permute,split --host-prefixes prefixes.txt -x \"&,=\" -D")
+	fmt.Println("  urlshort -f forbidden.txt --strategy bypass | httpx -mc 200")
+	fmt.Println("  urlshort -f urls.txt --strategy pollute --pollute-value '<x>' -D")
+	fmt.Println("  urlshort -f urls.txt -x \"&,=\" -D --sort interleave | httpx")
+	fmt.Println("  urlshort -f urls.txt -x \"&,=,/,.\" --max-depth 3 --max-variations-per-url 200 -D")
+	fmt.Println("  urlshort -f urls.txt --strategy none -F xss.txt --inject replace -D")
+	fmt.Println("  urlshort -f api.txt --strategy none -F ids.txt --inject path,path-append")
+	fmt.Println("  echo 'https://x.com/api/FUZZ?id=FUZZ2' | urlshort -w paths.txt -w ids.txt:FUZZ2 --fuzz-mode pitchfork")
+	fmt.Println("  urlshort -f urls.txt --strategy none -F xss.txt --inject replace --encode url --encode url,url --encode-raw")
+	fmt.Println("  urlshort -f urls.txt --strategy none -a '\"><script src=//{{canary}}.oast.me></script>' --inject replace --canary-map canaries.tsv")
 }
 
 // Reads all non-empty lines from a file into a slice of strings.
 func readLines(path string) ([]string, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
 	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text()) // Trim whitespace
-		if line != "" {                           // Skip empty lines
-			lines = append(lines, line)
-		}
-	}
-	return lines, scanner.Err()
+	err := scanLines(path, func(line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	return lines, err
 }
 
-// Writes a slice of strings to a file, each on a new line.
-func writeLines(path string, lines []string) error {
-	file, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+// processOptions holds the settings that turn one input URL into its variations.
+type processOptions struct {
+	strategies []strategy
+	injectors  []injector
+	payloads   []string          // from -F, or the single -a string
+	encoder    *payloadEncoder   // --encode chains; nil means payloads are used as is
+	templater  *payloadTemplater // expands {{placeholders}} in payloads; nil if none are used
+	fuzz       *fuzzer           // expands FUZZ-marker templates; nil without -w or payloads
+	dedup      *dedupFilter      // nil unless -D is set
+	patterns   *patternFilter    // --smart-dedup on generated URLs; nil unless output or both
+	canonical  *canonicalizer    // canonical form for -D keys; nil unless --canonical
+
+	maxVariations int          // --max-variations-per-url; 0 means no limit
+	caps          *capReporter // warns about URLs that hit --max-depth or --max-variations-per-url
+}
 
-	writer := bufio.NewWriter(file)
-	for _, line := range lines {
-		_, err := writer.WriteString(line + "\n")
-		if err != nil {
-			return err // Return error immediately if write fails
-		}
-	}
-	// Flush ensures all buffered data is written to the file
-	return writer.Flush()
+// processConfig holds the settings from flags that newProcessOptions turns
+// into processOptions.
+type processConfig struct {
+	strategyList  string         // --strategy
+	strategy      strategyConfig // strategy settings; its delimiters are filled in from -x and -p
+	delimiters    string         // -x
+	splitPath     bool           // -p
+	maxVariations int            // --max-variations-per-url
+
+	injectList    string   // --inject
+	appendString  string   // -a
+	appendStrings []string // lines of -F, which take priority over -a
+	encodeChains  []string // --encode, one chain per flag
+	encodeRaw     bool     // --encode-raw
+	canaryMap     string   // --canary-map
+	wordlists     []string // -w
+	fuzzMode      string   // --fuzz-mode
+
+	dedup          *dedupFilter   // nil unless -D is set
+	patterns       *patternFilter // --smart-dedup on generated URLs; nil unless output or both
+	canonical      bool           // --canonical, or implied by the two below
+	dropFragment   bool           // --drop-fragment
+	writeCanonical bool           // --write-canonical
 }
 
-// Processes URLs based on specified options: delimiters, duplicates, path splitting, appends.
-func processURLs(urls []string, delimiters string, noDuplicates bool, splitPath bool, appendString string, appendStrings []string) []string {
+// newProcessOptions prepares the strategies, delimiter list and append settings used by processURLs.
+func newProcessOptions(cfg processConfig) (processOptions, error) {
 	// Prepare delimiters
-	rawDelimList := strings.Split(delimiters, ",")
+	rawDelimList := strings.Split(cfg.delimiters, ",")
 	// Filter out empty strings that might result from trailing commas, etc.
 	delimList := []string{}
 	for _, d := range rawDelimList {
@@ -311,7 +608,7 @@ func processURLs(urls []string, delimiters string, noDuplicates bool, splitPath
 	}
 
 	// Add path delimiter if requested, ensuring it's not duplicated if already present
-	if splitPath {
+	if cfg.splitPath {
 		found := false
 		for _, d := range delimList {
 			if d == "/" {
@@ -328,130 +625,259 @@ func processURLs(urls []string, delimiters string, noDuplicates bool, splitPath
 		fmt.Fprintf(os.Stderr, "%sWarning: No valid delimiters specified. Only applying appends.%s\n", colorYellow, colorReset)
 	}
 
-	// Use map for efficient duplicate checking if needed
-	seen := make(map[string]bool)
-	var result []string
+	if cfg.strategy.maxDepth < 0 || cfg.maxVariations < 0 {
+		return processOptions{}, fmt.Errorf("--max-depth and --max-variations-per-url can't be negative")
+	}
+	caps := &capReporter{}
+	cfg.strategy.delimiters = delimList
+	cfg.strategy.caps = caps
+	strategies, err := newStrategies(cfg.strategyList, cfg.strategy)
+	if err != nil {
+		return processOptions{}, err
+	}
+	injectors, err := newInjectors(cfg.injectList)
+	if err != nil {
+		return processOptions{}, err
+	}
 
-	for _, url := range urls {
-		// Generate base variations based on delimiters
-		baseVariations := generateVariations(url, delimList)
+	// Strings from the -F file take priority over a single -a string
+	payloads := cfg.appendStrings
+	if len(payloads) == 0 && cfg.appendString != "" {
+		payloads = []string{cfg.appendString}
+	}
+	encoder, err := newPayloadEncoder(cfg.encodeChains, cfg.encodeRaw)
+	if err != nil {
+		return processOptions{}, err
+	}
+	fuzz, err := newFuzzer(cfg.wordlists, cfg.fuzzMode, payloads, encoder)
+	if err != nil {
+		return processOptions{}, err
+	}
+	var canonical *canonicalizer
+	if cfg.canonical {
+		canonical = &canonicalizer{dropFragment: cfg.dropFragment, emit: cfg.writeCanonical}
+	}
 
-		for _, variation := range baseVariations {
-			// Apply append operations to each base variation
-			finalURLs := applyAppends(variation, appendString, appendStrings)
+	return processOptions{
+		strategies: strategies,
+		injectors:  injectors,
+		payloads:   payloads,
+		encoder:    encoder,
+		templater:  newPayloadTemplater(payloads, cfg.canaryMap),
+		fuzz:       fuzz,
+		dedup:      cfg.dedup,
+		patterns:   cfg.patterns,
+		canonical:  canonical,
+
+		maxVariations: cfg.maxVariations,
+		caps:          caps,
+	}, nil
+}
 
-			// Add final URLs to the result list, handling duplicates if requested
-			for _, finalURL := range finalURLs {
-				if noDuplicates {
-					if !seen[finalURL] {
-						seen[finalURL] = true
-						result = append(result, finalURL)
-					}
-				} else {
-					result = append(result, finalURL)
+// Processes URLs from source one at a time based on the given options: delimiters,
+// duplicates, path splitting, appends. Variations are generated lazily and every
+// final URL is handed to emit as soon as it is produced, so memory use does not
+// depend on the size of the input or of the output. Returns the number of input URLs read.
+func processURLs(source urlSource, opts processOptions, emit func(rec urlRecord) error) (int, error) {
+	count := 0
+
+	err := source(func(in urlRecord) error {
+		count++
+		// Pass final URLs on, handling duplicates if requested. Duplicates are
+		// judged before payload placeholders are filled in, so a repeated
+		// request is dropped even though it would get a fresh {{canary}}
+		send := func(p pendingURL) error {
+			key := p.url
+			if opts.canonical != nil {
+				key = opts.canonical.form(p.url)
+			}
+			if opts.dedup != nil && opts.dedup.seen(key) {
+				return nil
+			}
+			finalURL := p.url
+			if opts.canonical != nil && opts.canonical.emit {
+				finalURL = key
+			}
+			if opts.patterns != nil && opts.patterns.seen(finalURL) {
+				return nil
+			}
+			if p.fill != nil {
+				finalURL = p.fill()
+				if opts.canonical != nil && opts.canonical.emit {
+					finalURL = opts.canonical.form(finalURL)
 				}
 			}
+			// Every variation keeps the source file of the URL it came from
+			return emit(urlRecord{URL: finalURL, Source: in.Source})
 		}
-	}
 
-	return result
-}
+		// URLs with FUZZ-style markers are templates: their markers are the
+		// injection points, so they are expanded instead of shortened
+		if template := opts.fuzz.parse(in.URL); template != nil {
+			for p := range opts.fuzz.expand(template, opts.templater, in) {
+				if err := send(p); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
 
-// Generates variations of a URL by splitting it at given delimiters
-// and taking prefixes ending at each delimiter instance. Includes the original URL.
-func generateVariations(url string, delimiters []string) []string {
-	// Use map to automatically handle duplicates during generation
-	variations := make(map[string]bool)
-	variations[url] = true // Always include the original URL
+		// Generate base variations with the selected strategies
+		for variation := range opts.variations(in.URL) {
+			// Put the payloads into each base variation
+			for p := range opts.applyPayloads(in, variation) {
+				if err := send(p); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return count, err
+}
 
-	if len(delimiters) == 0 {
-		// If no delimiters, just return the original URL
-		return []string{url}
+// variations runs every selected strategy on rawURL in turn. With more than one
+// strategy, a variation produced by several of them is only passed on once.
+// With --max-variations-per-url, only that many variations are passed on.
+// Variations come out shortest first, so runs over the same input always give
+// the same output.
+func (opts processOptions) variations(rawURL string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		// Only this URL's variations are held (no more than --max-variations-per-url
+		// when it is set), so memory does not grow with the input
+		var variations []string
+		seen := make(map[string]bool)
+	generate:
+		for _, strategy := range opts.strategies {
+			for variation := range strategy(rawURL) {
+				if seen[variation] {
+					continue
+				}
+				if opts.maxVariations > 0 && len(variations) == opts.maxVariations {
+					// Only warn when there really was another variation to skip
+					opts.caps.warn(rawURL, fmt.Sprintf("--max-variations-per-url %d", opts.maxVariations))
+					break generate
+				}
+				seen[variation] = true
+				variations = append(variations, variation)
+			}
+		}
+		byLength(variations)
+		for _, variation := range variations {
+			if !yield(variation) {
+				return
+			}
+		}
 	}
+}
 
-	// Use a queue for breadth-first processing of variations and delimiters
-	queue := []string{url}
-	processed := make(map[string]bool) // Keep track of processed URLs to avoid loops
-	processed[url] = true
+// Generates variations of a URL by splitting it at given delimiters
+// and taking prefixes ending at each delimiter instance. Includes the original URL.
+// One pass per delimiter over the URL finds every prefix: splitting a prefix
+// again would only give prefixes of the URL that were already found.
+// Variations are yielded as they are found; only the variations of this one URL
+// are held in memory, to skip a prefix found by more than one delimiter.
+// With maxDepth > 0, each delimiter cuts the URL at most maxDepth times, at its
+// last occurrences, and onCap is called if that left cuts out.
+func generateVariations(url string, delimiters []string, maxDepth int, onCap func()) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		// Always include the original URL
+		if !yield(url) {
+			return
+		}
+		if len(delimiters) == 0 {
+			// If no delimiters, just the original URL
+			return
+		}
 
-	head := 0
-	for head < len(queue) {
-		currentURL := queue[head]
-		head++
+		processed := make(map[string]bool) // Prefixes already yielded
+		processed[url] = true
+		capped := false
 
 		for _, delim := range delimiters {
 			// Ensure delimiter is not empty
 			if delim == "" {
 				continue
 			}
-			parts := strings.Split(currentURL, delim)
+			parts := strings.Split(url, delim)
 			if len(parts) <= 1 { // No delimiter found or only one part
 				continue
 			}
 
-			// Generate prefixes ending with the delimiter
+			// Generate prefixes ending with the delimiter, the deepest cuts only
+			// when there are more than maxDepth of them
+			first := 0
+			if cuts := len(parts) - 1; maxDepth > 0 && cuts > maxDepth {
+				first = cuts - maxDepth
+				if !capped {
+					capped = true
+					onCap()
+				}
+			}
 			currentPrefix := ""
 			for i := 0; i < len(parts)-1; i++ {
 				currentPrefix += parts[i] + delim
-				if !variations[currentPrefix] {
-					variations[currentPrefix] = true
-					if !processed[currentPrefix] {
-						queue = append(queue, currentPrefix) // Add to queue for further splitting
-						processed[currentPrefix] = true
-					}
+				if i < first {
+					continue
 				}
-			}
-			// Check the full original string split/rejoined as well (though it should be currentURL)
-			fullSplitRejoined := strings.Join(parts, delim)
-			if !variations[fullSplitRejoined] {
-				variations[fullSplitRejoined] = true
-				if !processed[fullSplitRejoined] {
-					queue = append(queue, fullSplitRejoined)
-					processed[fullSplitRejoined] = true
+				if !processed[currentPrefix] {
+					processed[currentPrefix] = true
+					if !yield(currentPrefix) {
+						return
+					}
 				}
 			}
 		}
 	}
-
-	// Convert map keys to slice for the final result
-	result := make([]string, 0, len(variations))
-	for v := range variations {
-		result = append(result, v)
-	}
-
-	return result
 }
 
-// Appends strings to a base URL. Prioritizes strings from a file over a single string.
-func applyAppends(baseURL string, appendString string, appendStrings []string) []string {
-	// If no append operation is needed, return the base URL directly in a slice
-	if appendString == "" && len(appendStrings) == 0 {
-		return []string{baseURL}
-	}
-
-	var result []string
+// pendingURL is a generated URL whose payload placeholders are not filled in
+// yet. url has them as written, so that -D sees a repeated request as one even
+// though every fill draws a fresh {{canary}} and {{rand}}; fill builds the URL
+// that is written out and records its canary. fill is nil without placeholders.
+type pendingURL struct {
+	url  string
+	fill func() string
+}
 
-	if len(appendStrings) > 0 {
-		// If a list of strings to append is provided (from -F file), use them.
-		result = make([]string, len(appendStrings))
-		for i, s := range appendStrings {
-			result[i] = baseURL + s
+// applyPayloads puts every payload into every injection point of a variation,
+// one result per (point, payload) pair. With the default "append" mode this is
+// the original behaviour of concatenating each payload to the end. Each payload
+// is run through the --encode chains, giving one result per encoded form; its
+// {{placeholders}} are filled in for this URL and point by the result's fill,
+// before encoding.
+// Without any payloads the variation is passed through unchanged.
+// Results are yielded one at a time rather than collected into a slice.
+func (opts processOptions) applyPayloads(in urlRecord, variation string) iter.Seq[pendingURL] {
+	return func(yield func(pendingURL) bool) {
+		if len(opts.payloads) == 0 {
+			yield(pendingURL{url: variation})
+			return
 		}
-	} else {
-		// Check appendString again, as len(appendStrings) could be 0
-		if appendString != "" {
-			// Otherwise, if a single append string is provided (from -a), use it.
-			result = []string{baseURL + appendString}
-		} else {
-			// This case means appendString was "" and appendStrings was empty, handled above.
-			// But for robustness, return the original if somehow reached.
-			result = []string{baseURL}
+		var ctx payloadContext
+		if opts.templater != nil {
+			ctx = newPayloadContext(in, variation)
+		}
+		for _, inject := range opts.injectors {
+			for _, point := range inject(variation) {
+				for _, payload := range opts.payloads {
+					for _, chain := range opts.encoder.formChains(payload) {
+						p := pendingURL{url: point.apply(opts.encoder.encode(payload, chain))}
+						if opts.templater.uses(payload) {
+							p.fill = func() string {
+								ctx.param = point.name
+								expanded, canary := opts.templater.expand(payload, ctx)
+								finalURL := point.apply(opts.encoder.encode(expanded, chain))
+								opts.templater.record(canary, ctx, finalURL)
+								return finalURL
+							}
+						}
+						if !yield(p) {
+							return
+						}
+					}
+				}
+			}
 		}
 	}
-
-	return result
 }
-
-// NOTE: The functions processFind, processFindX, parseKeywords,
-// generateOutputFileName, and saveUrlsToFile should be in the 'find.go' file
-// alongside this 'main.go' file in the same package ('main').
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// noiseHosts are analytics, tracking and font hosts whose URLs are never worth
+// generating variations for. Subdomains match too; a "host/path" rule only
+// matches that path on the host. Vendors that also serve a real app (HubSpot,
+// Sentry, Segment, ...) are only listed by their beacon and collector hosts,
+// never by their own domain.
+var noiseHosts = []string{
+	"google-analytics.com", "googletagmanager.com", "googleadservices.com",
+	"doubleclick.net", "googlesyndication.com", "fonts.googleapis.com", "fonts.gstatic.com",
+	"connect.facebook.net", "facebook.com/tr", "static.hotjar.com", "script.hotjar.com",
+	"api.segment.io/v1", "cdn.segment.com/analytics.js", "api-js.mixpanel.com", "cdn.mxpnl.com",
+	"nr-data.net", "js-agent.newrelic.com", "clarity.ms", "bat.bing.com",
+	"js.hs-analytics.net", "js.hs-scripts.com", "track.hubspot.com",
+	"widget.intercom.io", "js.intercomcdn.com", "browser.sentry-cdn.com", "ingest.sentry.io",
+}
+
+// noiseFilter drops static assets and beacons from the input before any
+// variations are generated for them, counting what it drops and why.
+type noiseFilter struct {
+	exts    []string // extensions to drop, without the dot
+	allow   []string // allowlist mode: only these extensions (or none) are kept
+	hosts   []string // host rules; "host/path" rules also match a path prefix
+	dropped map[string]int
+	total   int
+}
+
+// newNoiseFilter builds the filter from comma separated lists. An empty list
+// uses the built-in defaults and "none" turns that check off. An allowlist
+// replaces the extension blacklist; "none" allows only extensionless URLs.
+func newNoiseFilter(exts, hosts, allow string) *noiseFilter {
+	f := &noiseFilter{dropped: make(map[string]int)}
+	f.exts = noiseList(exts, staticExtensions)
+	f.hosts = noiseList(hosts, noiseHosts)
+	if allow != "" {
+		// "none" still means allowlist mode, keeping only extensionless URLs
+		f.allow = noiseList(allow, nil)
+		if f.allow == nil {
+			f.allow = []string{}
+		}
+		f.exts = nil
+	}
+	for i, ext := range f.exts {
+		f.exts[i] = strings.TrimPrefix(ext, ".")
+	}
+	for i, ext := range f.allow {
+		f.allow[i] = strings.TrimPrefix(ext, ".")
+	}
+	return f
+}
+
+// noiseList parses one of the --noise-* lists, falling back to defaults.
+func noiseList(list string, defaults []string) []string {
+	if strings.EqualFold(strings.TrimSpace(list), "none") {
+		return nil
+	}
+	if list == "" {
+		return slices.Clone(defaults)
+	}
+	var items []string
+	for _, item := range parseKeywords(list) {
+		items = append(items, strings.ToLower(item))
+	}
+	return items
+}
+
+// reason says why rawURL is noise, or returns "" if it should be kept.
+func (f *noiseFilter) reason(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "" // not ours to judge; --validate handles broken lines
+	}
+	host, path := strings.ToLower(u.Hostname()), u.EscapedPath()
+	for _, rule := range f.hosts {
+		ruleHost, rulePath, hasPath := strings.Cut(rule, "/")
+		if host != ruleHost && !strings.HasSuffix(host, "."+ruleHost) {
+			continue
+		}
+		// "facebook.com/tr" matches /tr and /tr/..., but not /trending
+		if !hasPath || path == "/"+rulePath || strings.HasPrefix(path, "/"+rulePath+"/") {
+			return rule
+		}
+	}
+
+	segments := pathSegments(path)
+	if len(segments) == 0 {
+		return ""
+	}
+	last := segments[len(segments)-1]
+	name, ok := splitFileName(last)
+	if !ok {
+		return "" // extensionless routes are kept in both modes
+	}
+	ext := strings.ToLower(last[len(name)+1:])
+	if f.allow != nil {
+		if !slices.Contains(f.allow, ext) {
+			return "." + ext + " (not allowed)"
+		}
+		return ""
+	}
+	if slices.Contains(f.exts, ext) {
+		return "." + ext
+	}
+	return ""
+}
+
+// wrap returns a source that skips noise URLs.
+func (f *noiseFilter) wrap(source urlSource) urlSource {
+	return func(fn func(rec urlRecord) error) error {
+		return source(func(rec urlRecord) error {
+			if reason := f.reason(rec.URL); reason != "" {
+				f.dropped[reason]++
+				f.total++
+				return nil
+			}
+			return fn(rec)
+		})
+	}
+}
+
+// summary lists the drop reasons, most frequent first: ".png 600, .css 300, ...".
+func (f *noiseFilter) summary() string {
+	reasons := make([]string, 0, len(f.dropped))
+	for reason := range f.dropped {
+		reasons = append(reasons, reason)
+	}
+	slices.SortFunc(reasons, func(a, b string) int {
+		if c := cmp.Compare(f.dropped[b], f.dropped[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	parts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		parts[i] = fmt.Sprintf("%s %d", reason, f.dropped[reason])
+	}
+	return strings.Join(parts, ", ")
+}
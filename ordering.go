@@ -0,0 +1,105 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// sortModes lists the --sort values in the order they are documented.
+var sortModes = []string{"input", "shortest-first", "longest-first", "lexical", "host", "interleave"}
+
+// outputSorter holds every generated URL so it can be written out in a global
+// order. The default "input" order needs no sorter: URLs stream straight to
+// the output in input order, each URL's variations shortest first.
+type outputSorter struct {
+	mode    string
+	records []urlRecord
+}
+
+// newOutputSorter checks the --sort mode. It returns nil for "input".
+func newOutputSorter(mode string) (*outputSorter, error) {
+	mode = strings.ToLower(mode)
+	if !slices.Contains(sortModes, mode) {
+		return nil, fmt.Errorf("unknown sort order '%s' (use %s)", mode, strings.Join(sortModes, ", "))
+	}
+	if mode == "input" {
+		return nil, nil
+	}
+	return &outputSorter{mode: mode}, nil
+}
+
+// write collects a generated URL until flush.
+func (s *outputSorter) write(rec urlRecord) error {
+	s.records = append(s.records, rec)
+	return nil
+}
+
+// flush orders the collected URLs and hands them to emit. Sorts are stable,
+// so ties keep the input order.
+func (s *outputSorter) flush(emit func(rec urlRecord) error) error {
+	records := s.records
+	s.records = nil
+
+	switch s.mode {
+	case "shortest-first":
+		slices.SortStableFunc(records, func(a, b urlRecord) int { return cmp.Compare(len(a.URL), len(b.URL)) })
+	case "longest-first":
+		slices.SortStableFunc(records, func(a, b urlRecord) int { return cmp.Compare(len(b.URL), len(a.URL)) })
+	case "lexical":
+		slices.SortStableFunc(records, func(a, b urlRecord) int { return strings.Compare(a.URL, b.URL) })
+	case "host":
+		slices.SortStableFunc(records, func(a, b urlRecord) int { return strings.Compare(hostOf(a.URL), hostOf(b.URL)) })
+	case "interleave":
+		records = interleaveHosts(records)
+	}
+
+	for _, rec := range records {
+		if err := emit(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// interleaveHosts deals the URLs out round-robin, one per host at a time with
+// hosts in order of first appearance, so consecutive requests hit different
+// hosts instead of hammering one.
+func interleaveHosts(records []urlRecord) []urlRecord {
+	var hosts []string
+	byHost := make(map[string][]urlRecord)
+	for _, rec := range records {
+		host := hostOf(rec.URL)
+		if _, ok := byHost[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		byHost[host] = append(byHost[host], rec)
+	}
+
+	interleaved := make([]urlRecord, 0, len(records))
+	for round := 0; len(interleaved) < len(records); round++ {
+		for _, host := range hosts {
+			if round < len(byHost[host]) {
+				interleaved = append(interleaved, byHost[host][round])
+			}
+		}
+	}
+	return interleaved
+}
+
+// hostOf returns the lowercased host of rawURL, or "" if it doesn't parse.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// byLength orders one URL's variations shortest first, ties in the order they
+// were generated.
+func byLength(variations []string) {
+	slices.SortStableFunc(variations, func(a, b string) int { return cmp.Compare(len(a), len(b)) })
+}
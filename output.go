@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// msgOut receives the banner and status messages. It is stdout by default and
+// switched to stderr when stdout is piped, so that only URLs go down the pipe.
+var msgOut io.Writer = os.Stdout
+
+// outputSink receives generated URLs one at a time and fans them out to the
+// console, the -o file and the --find/--findX result files.
+type outputSink struct {
+	console   *bufio.Writer // nil in quiet mode
+	highlight bool          // colour --find/--findX matches on the console
+	source    bool          // append the input file name to every line
+	path      string
+	file      io.WriteCloser
+	writer    *bufio.Writer
+	find      *findResults
+	findX     *findResults
+	count     int
+}
+
+// newOutputSink creates the sink. The -o file, if any, is created up front so
+// that a bad path fails before any work is done.
+func newOutputSink(outputFile string, quietMode bool, withSource bool, find, findX *findResults) (*outputSink, error) {
+	sink := &outputSink{source: withSource, find: find, findX: findX}
+	if !quietMode {
+		sink.console = bufio.NewWriter(os.Stdout)
+		sink.highlight = stdoutIsTerminal()
+	}
+	if outputFile != "" {
+		file, err := createOutput(outputFile)
+		if err != nil {
+			return nil, err
+		}
+		sink.path = outputFile
+		sink.file = file
+		sink.writer = bufio.NewWriter(file)
+	}
+	return sink, nil
+}
+
+// write sends a single generated URL to every configured destination.
+func (s *outputSink) write(rec urlRecord) error {
+	s.count++
+
+	// Keywords are matched against the URL only; the source is just carried along
+	line := rec.URL
+	if s.source {
+		line += "\t" + rec.Source
+	}
+
+	// Check if the URL matched either find condition
+	isFindMatch := s.find.match(rec.URL)
+	isFindXMatch := s.findX.match(rec.URL)
+	if isFindMatch {
+		if err := s.find.save(line); err != nil {
+			return fmt.Errorf("saving --find results: %w", err)
+		}
+	}
+	if isFindXMatch {
+		if err := s.findX.save(line); err != nil {
+			return fmt.Errorf("saving --findX results: %w", err)
+		}
+	}
+
+	if s.console != nil {
+		if (isFindMatch || isFindXMatch) && s.highlight {
+			// Print in green if it matched either
+			fmt.Fprintf(s.console, "%s%s%s\n", colorGreen, line, colorReset)
+		} else {
+			fmt.Fprintln(s.console, line)
+		}
+	}
+
+	// Note: The -o file receives *all* generated URLs, not just the found ones.
+	if s.writer != nil {
+		if _, err := s.writer.WriteString(line + "\n"); err != nil {
+			return fmt.Errorf("writing to output file '%s': %w", s.path, err)
+		}
+	}
+	return nil
+}
+
+// close flushes all destinations and closes any files that were opened.
+func (s *outputSink) close() error {
+	var firstErr error
+	if s.console != nil {
+		if err := s.console.Flush(); err != nil {
+			firstErr = err
+		}
+	}
+	if s.file != nil {
+		if err := s.writer.Flush(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("writing to output file '%s': %w", s.path, err)
+		}
+		if err := s.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := s.find.close(); err != nil && firstErr == nil {
+		firstErr = fmt.Errorf("saving --find results: %w", err)
+	}
+	if err := s.findX.close(); err != nil && firstErr == nil {
+		firstErr = fmt.Errorf("saving --findX results: %w", err)
+	}
+	return firstErr
+}
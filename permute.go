@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"iter"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// permuter builds the "permute" strategy: the same app reached over other
+// schemes, ports and sibling hosts.
+type permuter struct {
+	schemes      []string
+	ports        []string
+	hostPrefixes []string // e.g. "dev-", "staging.", "api."
+}
+
+// newPermuter checks the --permute-schemes and --permute-ports lists.
+func newPermuter(schemes, ports string, hostPrefixes []string) (*permuter, error) {
+	p := &permuter{hostPrefixes: hostPrefixes}
+	for _, scheme := range parseKeywords(schemes) {
+		scheme = strings.ToLower(scheme)
+		if scheme != "http" && scheme != "https" {
+			return nil, fmt.Errorf("unsupported scheme '%s' in --permute-schemes (use http, https)", scheme)
+		}
+		p.schemes = append(p.schemes, scheme)
+	}
+	for _, port := range parseKeywords(ports) {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("invalid port '%s' in --permute-ports", port)
+		}
+		p.ports = append(p.ports, port)
+	}
+	return p, nil
+}
+
+// variations yields the URL itself, then every combination of host (the
+// original and each prefixed one), scheme and port. The URL's own scheme and
+// port, and each scheme's default port, are always part of the combinations;
+// default ports are left out of the URL. Everything after the host stays as it was.
+func (p *permuter) variations(rawURL string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		if !yield(rawURL) {
+			return
+		}
+		parts, ok := parseURLParts(rawURL)
+		if !ok {
+			return
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return
+		}
+		rest := strings.TrimPrefix(parts.String(), parts.prefix)
+		userinfo := ""
+		if u.User != nil {
+			userinfo = u.User.String() + "@"
+		}
+
+		host := u.Hostname()
+		hosts := []string{host}
+		if net.ParseIP(host) == nil { // "dev-10.0.0.1" is not a host
+			for _, prefix := range p.hostPrefixes {
+				hosts = append(hosts, prefix+host)
+			}
+		}
+		schemes := appendMissing([]string{strings.ToLower(u.Scheme)}, p.schemes...)
+		// An input on its scheme's default port stays on the default port of each
+		// scheme, so https://x.com gives http://x.com rather than http://x.com:443
+		port := u.Port()
+		if port == defaultPorts[strings.ToLower(u.Scheme)] {
+			port = ""
+		}
+		// The default port is always tried, so https://x.com:8080 gives https://x.com too
+		ports := appendMissing([]string{port, ""}, p.ports...)
+
+		seen := map[string]bool{rawURL: true}
+		for _, host := range hosts {
+			for _, scheme := range schemes {
+				for _, port := range ports {
+					authority := host
+					if strings.Contains(host, ":") { // IPv6
+						authority = "[" + host + "]"
+					}
+					if port != "" && port != defaultPorts[scheme] {
+						authority = net.JoinHostPort(host, port)
+					}
+					variation := scheme + "://" + userinfo + authority + rest
+					if seen[variation] {
+						continue
+					}
+					seen[variation] = true
+					if !yield(variation) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// appendMissing appends the values not already in list.
+func appendMissing(list []string, values ...string) []string {
+	for _, v := range values {
+		if !slices.Contains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
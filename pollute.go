@@ -0,0 +1,67 @@
+package main
+
+import (
+	"iter"
+	"strings"
+)
+
+// pollutionVariations returns the "pollute" strategy: the URL itself, then for
+// every query parameter the variants that trip up parsers disagreeing on
+// duplicate, nested or oddly separated parameters. For "?a=1&b=2" and value P:
+//
+//	a=1&a=P&b=2   the parameter duplicated with value P
+//	a=1;a=P&b=2   the duplicate after a semicolon instead of "&"
+//	a[]=1&b=2     PHP/Rails array syntax
+//	a[0]=1&b=2    PHP/Rails indexed array syntax
+//	a.x=1&b=2     dotted (JSON-ish) key
+//
+// followed by the whole query joined with semicolons: "a=1;b=2".
+func pollutionVariations(value string) strategy {
+	return func(rawURL string) iter.Seq[string] {
+		return func(yield func(string) bool) {
+			if !yield(rawURL) {
+				return
+			}
+			parts, ok := parseURLParts(rawURL)
+			if !ok || len(parts.params) == 0 {
+				return
+			}
+
+			params := parts.params
+			for i, param := range params {
+				duplicate := queryParam{name: param.name, value: value, hasValue: true}
+				withDuplicate := append(append(append([]queryParam{}, params[:i+1]...), duplicate), params[i+1:]...)
+				if !yield(parts.withParams(withDuplicate)) {
+					return
+				}
+				// The duplicate glued on with ";" is a single parameter to parsers that only split on "&"
+				semicolon := append([]queryParam{}, params...)
+				semicolon[i] = queryParam{name: param.String() + ";" + duplicate.name, value: value, hasValue: true}
+				if !yield(parts.withParams(semicolon)) {
+					return
+				}
+				for _, renamed := range []string{param.name + "[]", param.name + "[0]", param.name + ".x"} {
+					variant := append([]queryParam{}, params...)
+					variant[i].name = renamed
+					if !yield(parts.withParams(variant)) {
+						return
+					}
+				}
+			}
+
+			if len(params) > 1 {
+				pairs := make([]string, len(params))
+				for i, param := range params {
+					pairs[i] = param.String()
+				}
+				semicolons := parts.prefix + parts.path + "?" + strings.Join(pairs, ";")
+				if parts.hasFragment {
+					semicolons += "#" + parts.fragment
+				}
+				if !yield(semicolons) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// scopeRule is one line of a scope file. Exactly one of host, wildcard or
+// network is set, unless the rule only restricts the path.
+type scopeRule struct {
+	host       string     // exact host, e.g. "example.com"
+	wildcard   string     // suffix for "*.example.com" rules, stored as ".example.com"
+	network    *net.IPNet // CIDR range for IP hosts
+	port       string     // optional port the URL must use
+	pathPrefix string     // optional path prefix, e.g. "/api"
+	raw        string     // the rule as written, for error messages
+}
+
+// scopeFilter drops input URLs that fall outside a bug bounty program's scope.
+// A URL is in scope when it matches at least one include rule (or there are no
+// include rules) and no exclude rule.
+type scopeFilter struct {
+	includes []scopeRule
+	excludes []scopeRule
+	dropped  int
+}
+
+// loadScope reads a scope file. Each non-empty line is one rule:
+//
+//	example.com            exact host
+//	*.example.com          any subdomain of example.com
+//	10.0.0.0/8             IP hosts in a CIDR range
+//	example.com/api        host plus path prefix (full URLs work too)
+//	/admin                 path prefix on any host
+//	!dev.example.com       lines starting with ! are exclude rules
+//
+// Everything after a # is a comment.
+func loadScope(path string) (*scopeFilter, error) {
+	filter := &scopeFilter{}
+	err := scanLines(path, func(line string) error {
+		// Drop comments, both whole-line and trailing ones
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			return nil
+		}
+		exclude := strings.HasPrefix(line, "!")
+		rule, err := parseScopeRule(strings.TrimSpace(strings.TrimPrefix(line, "!")))
+		if err != nil {
+			return err
+		}
+		if exclude {
+			filter.excludes = append(filter.excludes, rule)
+		} else {
+			filter.includes = append(filter.includes, rule)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(filter.includes) == 0 && len(filter.excludes) == 0 {
+		return nil, fmt.Errorf("scope file '%s' has no rules", path)
+	}
+	return filter, nil
+}
+
+// parseScopeRule turns one scope line into a rule.
+func parseScopeRule(entry string) (scopeRule, error) {
+	rule := scopeRule{raw: entry}
+	if entry == "" {
+		return rule, fmt.Errorf("empty scope rule")
+	}
+
+	// Bare path prefix that applies to every host
+	if strings.HasPrefix(entry, "/") {
+		rule.pathPrefix = entry
+		return rule, nil
+	}
+
+	// CIDR ranges contain a "/" that isn't a path
+	if _, network, err := net.ParseCIDR(entry); err == nil {
+		rule.network = network
+		return rule, nil
+	}
+
+	// Drop any scheme and split the host from the path prefix
+	if i := strings.Index(entry, "://"); i >= 0 {
+		entry = entry[i+3:]
+	}
+	hostPort, path, _ := strings.Cut(entry, "/")
+	if path != "" {
+		rule.pathPrefix = "/" + path
+	}
+
+	host := strings.ToLower(hostPort)
+	if h, p, err := net.SplitHostPort(hostPort); err == nil {
+		host, rule.port = strings.ToLower(h), p
+	}
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	switch {
+	case strings.HasPrefix(host, "*."):
+		rule.wildcard = host[1:]
+	case strings.Contains(host, "*"):
+		return rule, fmt.Errorf("unsupported wildcard in scope rule '%s' (only a leading *. is allowed)", rule.raw)
+	case host == "":
+		return rule, fmt.Errorf("missing host in scope rule '%s'", rule.raw)
+	default:
+		rule.host = host
+	}
+	return rule, nil
+}
+
+// matches reports whether a parsed URL falls under this rule.
+func (r scopeRule) matches(host string, ip net.IP, port string, path string) bool {
+	switch {
+	case r.host != "" && host != r.host:
+		return false
+	case r.wildcard != "" && !strings.HasSuffix(host, r.wildcard):
+		return false
+	case r.network != nil && (ip == nil || !r.network.Contains(ip)):
+		return false
+	}
+	if r.port != "" && port != r.port {
+		return false
+	}
+	if path == "" {
+		path = "/"
+	}
+	// Match whole segments: "/admin" covers /admin and /admin/..., not /administrator
+	return path == r.pathPrefix || strings.HasPrefix(path, strings.TrimSuffix(r.pathPrefix, "/")+"/")
+}
+
+// inScope reports whether rawURL passes the include and exclude rules.
+// URLs whose host can't be determined are treated as out of scope.
+func (f *scopeFilter) inScope(rawURL string) bool {
+	if !strings.Contains(rawURL, "://") && !strings.HasPrefix(rawURL, "//") {
+		rawURL = "//" + rawURL // Let net/url find the host of scheme-less input
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	ip := net.ParseIP(host)
+	port := u.Port()
+	if port == "" {
+		port = defaultPorts[strings.ToLower(u.Scheme)]
+	}
+	path := u.EscapedPath()
+
+	for _, rule := range f.excludes {
+		if rule.matches(host, ip, port, path) {
+			return false
+		}
+	}
+	if len(f.includes) == 0 {
+		return true
+	}
+	for _, rule := range f.includes {
+		if rule.matches(host, ip, port, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrap returns a urlSource that only passes on in-scope URLs.
+func (f *scopeFilter) wrap(source urlSource) urlSource {
+	return func(fn func(rec urlRecord) error) error {
+		return source(func(rec urlRecord) error {
+			if !f.inScope(rec.URL) {
+				f.dropped++
+				return nil
+			}
+			return fn(rec)
+		})
+	}
+}
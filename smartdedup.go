@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// staticExtensions are file extensions of static assets: images, fonts,
+// stylesheets and media. Such files rarely differ in anything that matters.
+var staticExtensions = []string{
+	"png", "jpg", "jpeg", "gif", "svg", "ico", "webp", "bmp", "tif", "tiff",
+	"css", "scss", "woff", "woff2", "ttf", "otf", "eot",
+	"mp3", "mp4", "avi", "webm", "pdf",
+}
+
+// smartDedupStages lists the --smart-dedup values in the order they are documented.
+var smartDedupStages = []string{"input", "output", "both"}
+
+// uuidPattern matches a UUID path segment, any version.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// patternFilter is the uro-style --smart-dedup: URLs that only differ in
+// parameter values, numeric or UUID path segments, or the extension of a
+// static asset share a pattern, and only the first URL of each pattern is
+// kept. Patterns are tracked in a fixed-size filter like -D.
+type patternFilter struct {
+	filter  *dedupFilter
+	dropped int
+}
+
+// newPatternFilter allocates a pattern filter using sizeMB megabytes.
+func newPatternFilter(sizeMB int) *patternFilter {
+	return &patternFilter{filter: newDedupFilter(sizeMB)}
+}
+
+// parseSmartDedup checks a --smart-dedup stage and reports whether input and
+// output are collapsed. An empty stage turns smart dedup off.
+func parseSmartDedup(stage string) (input, output bool, err error) {
+	switch strings.ToLower(stage) {
+	case "":
+		return false, false, nil
+	case "input":
+		return true, false, nil
+	case "output":
+		return false, true, nil
+	case "both":
+		return true, true, nil
+	}
+	return false, false, fmt.Errorf("unknown smart dedup stage '%s' (use %s)", stage, strings.Join(smartDedupStages, ", "))
+}
+
+// seen reports whether a URL with the same pattern as rawURL was seen before.
+func (f *patternFilter) seen(rawURL string) bool {
+	if f.filter.seen(urlPattern(rawURL)) {
+		f.dropped++
+		return true
+	}
+	return false
+}
+
+// wrap returns a source that passes on only the first input URL of each pattern.
+func (f *patternFilter) wrap(source urlSource) urlSource {
+	return func(fn func(rec urlRecord) error) error {
+		return source(func(rec urlRecord) error {
+			if f.seen(rec.URL) {
+				return nil
+			}
+			return fn(rec)
+		})
+	}
+}
+
+// urlPattern reduces a URL to its pattern: lowercased scheme and host, numeric
+// and UUID path segments replaced by placeholders, the extension of a static
+// asset dropped, and only the sorted parameter names of the query.
+// "/product/123?ref=a" and "/product/456?ref=b" both give "/product/{num}?ref".
+// URLs that don't parse are their own pattern.
+func urlPattern(rawURL string) string {
+	parts, ok := parseURLParts(rawURL)
+	if !ok {
+		return rawURL
+	}
+
+	segments := pathSegments(parts.path)
+	for i, segment := range segments {
+		switch {
+		case isNumeric(segment):
+			segments[i] = "{num}"
+		case uuidPattern.MatchString(segment):
+			segments[i] = "{uuid}"
+		case i == len(segments)-1:
+			if name, ok := splitFileName(segment); ok && slices.Contains(staticExtensions, strings.ToLower(segment[len(name)+1:])) {
+				segments[i] = name + ".{static}"
+			}
+		}
+	}
+	path := joinSegments(segments, strings.HasSuffix(parts.path, "/"))
+
+	var names []string
+	for _, param := range parts.params {
+		if !slices.Contains(names, param.name) {
+			names = append(names, param.name)
+		}
+	}
+	slices.Sort(names)
+
+	pattern := strings.ToLower(parts.prefix) + path
+	if len(names) > 0 {
+		pattern += "?" + strings.Join(names, "&")
+	}
+	return pattern
+}
+
+// isNumeric reports whether s is a non-empty run of digits.
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
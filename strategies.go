@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// A strategy turns one input URL into a stream of variations. Strategies are
+// selected by name with --strategy and run one after another on every URL.
+type strategy func(rawURL string) iter.Seq[string]
+
+// strategyNames lists the --strategy values in the order they are documented.
+var strategyNames = []string{"split", "params", "ancestors", "suffixes", "backups", "hidden-params", "pollute", "bypass", "permute", "none"}
+
+// strategyConfig holds the settings of the strategies that take any.
+type strategyConfig struct {
+	delimiters     []string        // split: -x delimiters, plus "/" with -p
+	maxDepth       int             // split, ancestors, suffixes: --max-depth, 0 means no limit
+	caps           *capReporter    // split, ancestors, suffixes: told about URLs cut short by maxDepth
+	backupPatterns []string        // backups: --backup-patterns, or the defaults
+	discovery      *paramDiscovery // hidden-params: nil without --param-wordlist
+	polluteValue   string          // pollute: value of the duplicated parameters
+	permuteSchemes string          // permute: --permute-schemes
+	permutePorts   string          // permute: --permute-ports
+	hostPrefixes   []string        // permute: --host-prefixes
+}
+
+// newStrategies builds the strategies named in a comma separated list.
+// "permute" expands the input first: the other strategies then run on every
+// permutation as well as on the input URL.
+func newStrategies(names string, cfg strategyConfig) ([]strategy, error) {
+	var strategies []strategy
+	var permute *permuter
+	depthCut := func(rawURL string) {
+		cfg.caps.warn(rawURL, fmt.Sprintf("--max-depth %d", cfg.maxDepth))
+	}
+	for _, name := range parseKeywords(names) {
+		switch strings.ToLower(name) {
+		case "permute":
+			p, err := newPermuter(cfg.permuteSchemes, cfg.permutePorts, cfg.hostPrefixes)
+			if err != nil {
+				return nil, err
+			}
+			permute = p
+		case "split":
+			strategies = append(strategies, func(rawURL string) iter.Seq[string] {
+				return generateVariations(rawURL, cfg.delimiters, cfg.maxDepth, func() { depthCut(rawURL) })
+			})
+		case "params":
+			strategies = append(strategies, paramVariations)
+		case "ancestors":
+			strategies = append(strategies, ancestorVariations(cfg.maxDepth, depthCut))
+		case "suffixes":
+			strategies = append(strategies, suffixVariations(cfg.maxDepth, depthCut))
+		case "backups":
+			patterns := cfg.backupPatterns
+			if len(patterns) == 0 {
+				patterns = defaultBackupPatterns
+			}
+			strategies = append(strategies, backupVariations(patterns))
+		case "bypass":
+			strategies = append(strategies, bypassVariations)
+		case "pollute":
+			strategies = append(strategies, pollutionVariations(cfg.polluteValue))
+		case "hidden-params":
+			if cfg.discovery == nil {
+				return nil, fmt.Errorf("the hidden-params strategy needs a --param-wordlist")
+			}
+			strategies = append(strategies, cfg.discovery.variations)
+		case "none":
+			// Only the input URL itself, e.g. to inject payloads into it without cutting it first
+			strategies = append(strategies, func(rawURL string) iter.Seq[string] {
+				return func(yield func(string) bool) { yield(rawURL) }
+			})
+		default:
+			return nil, fmt.Errorf("unknown strategy '%s' (use %s)", name, strings.Join(strategyNames, ", "))
+		}
+	}
+	if permute != nil {
+		for i, run := range strategies {
+			strategies[i] = func(rawURL string) iter.Seq[string] {
+				return func(yield func(string) bool) {
+					for permutation := range permute.variations(rawURL) {
+						for variation := range run(permutation) {
+							if !yield(variation) {
+								return
+							}
+						}
+					}
+				}
+			}
+		}
+		// The permutations themselves, also when no other strategy is selected
+		strategies = append([]strategy{permute.variations}, strategies...)
+	}
+	if len(strategies) == 0 {
+		return nil, fmt.Errorf("no strategy selected (use %s)", strings.Join(strategyNames, ", "))
+	}
+	return strategies, nil
+}
+
+// paramVariations is the "params" strategy. Rather than cutting the raw string
+// at delimiters, it parses the URL with net/url and generates variations per
+// component: each query parameter on its own, each parameter removed, the URL
+// without its query string, and each ancestor of the path. Values containing
+// "=" or an encoded "&" stay intact. URLs that don't parse yield only themselves.
+func paramVariations(rawURL string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		seen := make(map[string]bool)
+		emit := func(variation string) bool {
+			if seen[variation] {
+				return true
+			}
+			seen[variation] = true
+			return yield(variation)
+		}
+
+		// Always include the original URL
+		if !emit(rawURL) {
+			return
+		}
+		parts, ok := parseURLParts(rawURL)
+		if !ok {
+			return
+		}
+
+		// Each parameter on its own
+		params := parts.params
+		for i := range params {
+			if !emit(parts.withQuery(params[i : i+1])) {
+				return
+			}
+		}
+		// Each parameter removed (with a single parameter that's just the bare path, below)
+		if len(params) > 1 {
+			for i := range params {
+				rest := append(append([]queryParam{}, params[:i]...), params[i+1:]...)
+				if !emit(parts.withQuery(rest)) {
+					return
+				}
+			}
+		}
+		// No query string or fragment at all
+		if !emit(parts.withQuery(nil)) {
+			return
+		}
+		// Each ancestor of the path, without the query
+		for _, ancestor := range pathAncestors(parts.path) {
+			if !emit(parts.prefix + ancestor) {
+				return
+			}
+		}
+	}
+}
+
+// ancestorVariations returns the "ancestors" strategy, for directory discovery:
+// the URL itself, then every directory above its path without the query.
+// "/a/b/c.php?x=1" gives "/a/b/", "/a/" and "/". With maxDepth > 0 only the
+// maxDepth nearest directories are given, and onCap is told if there were more.
+func ancestorVariations(maxDepth int, onCap func(rawURL string)) strategy {
+	return func(rawURL string) iter.Seq[string] {
+		return func(yield func(string) bool) {
+			if !yield(rawURL) {
+				return
+			}
+			parts, ok := parseURLParts(rawURL)
+			if !ok {
+				return
+			}
+			ancestors := pathAncestors(parts.path)
+			if maxDepth > 0 && len(ancestors) > maxDepth {
+				ancestors = ancestors[:maxDepth]
+				onCap(rawURL)
+			}
+			for _, ancestor := range ancestors {
+				if !yield(parts.prefix + ancestor) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// suffixVariations returns the "suffixes" strategy: the URL itself, then the
+// path with its leading segments stripped one at a time, keeping the query and
+// fragment. "/a/b/c.php?x=1" gives "/b/c.php?x=1" and "/c.php?x=1", which
+// finds the same app mounted at a different base path. With maxDepth > 0 at
+// most maxDepth segments are stripped, and onCap is told if there were more.
+func suffixVariations(maxDepth int, onCap func(rawURL string)) strategy {
+	return func(rawURL string) iter.Seq[string] {
+		return func(yield func(string) bool) {
+			if !yield(rawURL) {
+				return
+			}
+			parts, ok := parseURLParts(rawURL)
+			if !ok {
+				return
+			}
+			segments := pathSegments(parts.path)
+			trailingSlash := strings.HasSuffix(parts.path, "/")
+			last := len(segments) - 1
+			if maxDepth > 0 && last > maxDepth {
+				last = maxDepth
+				onCap(rawURL)
+			}
+			for i := 1; i <= last; i++ {
+				if !yield(parts.withPath(joinSegments(segments[i:], trailingSlash))) {
+					return
+				}
+			}
+		}
+	}
+}
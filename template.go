@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// payloadVars lists the placeholders that can be used inside -a/-F payloads.
+var payloadVars = []string{"host", "path", "param", "index", "rand", "canary"}
+
+// payloadContext describes where a payload is being injected.
+type payloadContext struct {
+	input urlRecord // the input URL the variation came from
+	host  string
+	path  string
+	param string // the parameter or path segment receiving the payload
+}
+
+// newPayloadContext parses the variation once so every payload injected into
+// it can use {{host}} and {{path}}.
+func newPayloadContext(input urlRecord, variation string) payloadContext {
+	ctx := payloadContext{input: input}
+	if u, err := url.Parse(variation); err == nil {
+		ctx.host = u.Host
+		ctx.path = u.EscapedPath()
+	}
+	return ctx
+}
+
+// payloadTemplater expands {{placeholders}} in payloads for each generated URL,
+// so blind XSS and SSRF payloads carry the URL and parameter they were sent to.
+// Every {{canary}} gets a fresh random ID, recorded in the canary map so that
+// callbacks can be traced back to their source.
+type payloadTemplater struct {
+	index   int
+	mapPath string
+	file    io.WriteCloser // canary map, created on the first canary
+	writer  *bufio.Writer
+	err     error // first error writing the canary map; reported by close
+}
+
+// newPayloadTemplater returns a templater if any payload uses a placeholder,
+// or nil when there is nothing to expand.
+func newPayloadTemplater(payloads []string, mapPath string) *payloadTemplater {
+	for _, payload := range payloads {
+		if strings.Contains(payload, "{{") {
+			return &payloadTemplater{mapPath: mapPath}
+		}
+	}
+	return nil
+}
+
+// uses reports whether payload has placeholders to fill in.
+func (t *payloadTemplater) uses(payload string) bool {
+	return t != nil && strings.Contains(payload, "{{")
+}
+
+// expand fills in the placeholders of payload, returning it along with the
+// {{canary}} it was given ("" if none), for record once the URL is built.
+// It returns the payload as is if it has none. Unknown placeholders are left
+// untouched.
+func (t *payloadTemplater) expand(payload string, ctx payloadContext) (string, string) {
+	if !t.uses(payload) {
+		return payload, ""
+	}
+	t.index++
+
+	var b strings.Builder
+	canary := ""
+	for {
+		start := strings.Index(payload, "{{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(payload[start:], "}}")
+		if end < 0 {
+			break
+		}
+		end += start
+		b.WriteString(payload[:start])
+
+		name := strings.ToLower(strings.TrimSpace(payload[start+2 : end]))
+		switch name {
+		case "host":
+			b.WriteString(ctx.host)
+		case "path":
+			b.WriteString(ctx.path)
+		case "param":
+			b.WriteString(ctx.param)
+		case "index":
+			b.WriteString(strconv.Itoa(t.index))
+		case "rand":
+			b.WriteString(randomHex(4))
+		case "canary":
+			// One canary per generated URL, even if the payload uses it twice
+			if canary == "" {
+				canary = randomHex(6)
+			}
+			b.WriteString(canary)
+		default:
+			b.WriteString(payload[start : end+2])
+		}
+		payload = payload[end+2:]
+	}
+	b.WriteString(payload)
+	return b.String(), canary
+}
+
+// record writes one canary map line: canary, parameter, input URL, the URL
+// the canary was sent in and input file, tab separated.
+func (t *payloadTemplater) record(canary string, ctx payloadContext, sentURL string) {
+	if canary == "" || t.mapPath == "" || t.err != nil {
+		return
+	}
+	if t.writer == nil {
+		file, err := createOutput(t.mapPath)
+		if err != nil {
+			t.err = fmt.Errorf("creating canary map '%s': %w", t.mapPath, err)
+			return
+		}
+		t.file = file
+		t.writer = bufio.NewWriter(file)
+	}
+	if _, err := fmt.Fprintf(t.writer, "%s\t%s\t%s\t%s\t%s\n", canary, ctx.param, ctx.input.URL, sentURL, ctx.input.Source); err != nil {
+		t.err = fmt.Errorf("writing canary map '%s': %w", t.mapPath, err)
+	}
+}
+
+// close flushes the canary map and reports the first error seen while writing it.
+func (t *payloadTemplater) close() error {
+	if t == nil {
+		return nil
+	}
+	if t.file != nil {
+		if err := t.writer.Flush(); err != nil && t.err == nil {
+			t.err = fmt.Errorf("writing canary map '%s': %w", t.mapPath, err)
+		}
+		if err := t.file.Close(); err != nil && t.err == nil {
+			t.err = err
+		}
+	}
+	return t.err
+}
+
+// randomHex returns n random bytes as lowercase hex.
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf) // never returns an error
+	return hex.EncodeToString(buf)
+}
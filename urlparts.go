@@ -0,0 +1,169 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// queryParam is one name=value pair of a query string. Both halves keep
+// their raw percent-encoding, so "=" or "%26" inside a value survive untouched.
+type queryParam struct {
+	name     string
+	value    string
+	hasValue bool // false for a bare "flag" parameter with no "="
+}
+
+func (p queryParam) String() string {
+	if !p.hasValue {
+		return p.name
+	}
+	return p.name + "=" + p.value
+}
+
+// urlParts is a URL split into the components that the structured strategies
+// work on. Everything is kept in its raw, escaped form so that rebuilding a
+// URL from unchanged parts gives back the original text.
+type urlParts struct {
+	prefix      string // scheme://[userinfo@]host[:port]
+	path        string // escaped path; empty or starting with "/"
+	params      []queryParam
+	hasQuery    bool // a "?" was present, even with nothing after it
+	fragment    string
+	hasFragment bool
+}
+
+// parseURLParts parses rawURL with net/url. It reports false for anything that
+// isn't an absolute URL with a host.
+func parseURLParts(rawURL string) (*urlParts, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.Opaque != "" {
+		return nil, false
+	}
+
+	prefix := u.Scheme + "://"
+	if u.User != nil {
+		prefix += u.User.String() + "@"
+	}
+	parts := &urlParts{
+		prefix:      prefix + u.Host,
+		path:        u.EscapedPath(),
+		hasQuery:    u.ForceQuery || u.RawQuery != "",
+		fragment:    u.EscapedFragment(),
+		hasFragment: strings.Contains(rawURL, "#"),
+	}
+	parts.params = splitQuery(u.RawQuery)
+	return parts, true
+}
+
+// splitQuery splits a raw query string into its parameters, cutting each
+// pair at its first "=" only.
+func splitQuery(rawQuery string) []queryParam {
+	var params []queryParam
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(pair, "=")
+		params = append(params, queryParam{name: name, value: value, hasValue: hasValue})
+	}
+	return params
+}
+
+// joinQuery is the inverse of splitQuery.
+func joinQuery(params []queryParam) string {
+	pairs := make([]string, len(params))
+	for i, p := range params {
+		pairs[i] = p.String()
+	}
+	return strings.Join(pairs, "&")
+}
+
+// withQuery rebuilds the URL with the given parameters and no fragment.
+// An empty list drops the query string entirely.
+func (p *urlParts) withQuery(params []queryParam) string {
+	if len(params) == 0 {
+		return p.prefix + p.path
+	}
+	return p.prefix + p.path + "?" + joinQuery(params)
+}
+
+// withParams rebuilds the full URL, fragment included, with a different
+// set of query parameters.
+func (p *urlParts) withParams(params []queryParam) string {
+	s := p.prefix + p.path
+	if len(params) > 0 || p.hasQuery {
+		s += "?" + joinQuery(params)
+	}
+	if p.hasFragment {
+		s += "#" + p.fragment
+	}
+	return s
+}
+
+// withPath rebuilds the full URL with a different path, keeping the query
+// and fragment.
+func (p *urlParts) withPath(path string) string {
+	s := p.prefix + path + p.query()
+	if p.hasFragment {
+		s += "#" + p.fragment
+	}
+	return s
+}
+
+// joinSegments is the inverse of pathSegments; trailingSlash restores a
+// slash at the end of a directory path.
+func joinSegments(segments []string, trailingSlash bool) string {
+	path := "/" + strings.Join(segments, "/")
+	if trailingSlash && len(segments) > 0 {
+		path += "/"
+	}
+	return path
+}
+
+// query returns the original query string including its "?", or "".
+func (p *urlParts) query() string {
+	if !p.hasQuery {
+		return ""
+	}
+	return "?" + joinQuery(p.params)
+}
+
+// String rebuilds the full URL.
+func (p *urlParts) String() string {
+	s := p.prefix + p.path + p.query()
+	if p.hasFragment {
+		s += "#" + p.fragment
+	}
+	return s
+}
+
+// pathSegments splits an escaped path into its segments, ignoring the leading
+// and trailing slash.
+func pathSegments(path string) []string {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return nil
+	}
+	return strings.Split(trimmed, "/")
+}
+
+// pathAncestors returns the directories above path, deepest first and each
+// ending in "/": "/a/b/c.php" gives "/a/b/", "/a/" and "/".
+func pathAncestors(path string) []string {
+	segments := pathSegments(path)
+	if len(segments) == 0 {
+		return nil // already at the root
+	}
+	// Drop the last segment: either a file, or (for "/a/b/") the directory itself
+	segments = segments[:len(segments)-1]
+
+	var ancestors []string
+	for i := len(segments); i >= 0; i-- {
+		if i == 0 {
+			ancestors = append(ancestors, "/")
+		} else {
+			ancestors = append(ancestors, "/"+strings.Join(segments[:i], "/")+"/")
+		}
+	}
+	return ancestors
+}
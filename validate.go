@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// defaultPorts maps schemes to the port that can be dropped from their URLs.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// urlValidator checks every input line with net/url before it reaches the
+// generator, optionally normalising it, and reports the lines it rejects.
+type urlValidator struct {
+	normalize     bool
+	defaultScheme string // added to scheme-less lines when normalising
+	reportPath    string
+	report        io.WriteCloser // created on the first rejected line
+	writer        *bufio.Writer
+	rejected      int
+}
+
+// newURLValidator creates a validator. reportPath may be empty to only count rejects.
+func newURLValidator(normalize bool, defaultScheme string, reportPath string) *urlValidator {
+	return &urlValidator{
+		normalize:     normalize,
+		defaultScheme: strings.ToLower(strings.TrimSuffix(defaultScheme, "://")),
+		reportPath:    reportPath,
+	}
+}
+
+// wrap returns a urlSource that only passes on lines that are valid URLs.
+func (v *urlValidator) wrap(source urlSource) urlSource {
+	return func(fn func(rec urlRecord) error) error {
+		return source(func(rec urlRecord) error {
+			checked, reason := v.check(rec.URL)
+			if reason != "" {
+				return v.reject(rec, reason)
+			}
+			rec.URL = checked
+			return fn(rec)
+		})
+	}
+}
+
+// check validates a single line. It returns the (possibly normalised) URL,
+// or a non-empty reason when the line is rejected.
+func (v *urlValidator) check(line string) (string, string) {
+	if strings.IndexFunc(line, unicode.IsSpace) >= 0 {
+		return "", "contains whitespace"
+	}
+	if strings.IndexFunc(line, unicode.IsControl) >= 0 {
+		return "", "contains control characters"
+	}
+
+	u, err := url.Parse(line)
+	if err != nil {
+		return "", "parse error: " + unwrapURLError(err)
+	}
+	// "example.com/a" parses as a path and "example.com:8080/a" as scheme "example.com"
+	missingScheme := u.Scheme == "" || u.Opaque != "" && u.Opaque[0] >= '0' && u.Opaque[0] <= '9'
+	if missingScheme {
+		if !v.normalize || v.defaultScheme == "" {
+			return "", "missing scheme"
+		}
+		withScheme := v.defaultScheme + "://" + line
+		if strings.HasPrefix(line, "//") {
+			withScheme = v.defaultScheme + ":" + line // Protocol-relative
+		}
+		u, err = url.Parse(withScheme)
+		if err != nil {
+			return "", "parse error: " + unwrapURLError(err)
+		}
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if _, ok := defaultPorts[scheme]; !ok {
+		return "", fmt.Sprintf("unsupported scheme '%s'", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return "", "missing host"
+	}
+	if !validHostname(u.Hostname()) {
+		return "", fmt.Sprintf("invalid host '%s'", u.Hostname())
+	}
+	if port := u.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", fmt.Sprintf("invalid port '%s'", port)
+		}
+	}
+
+	if !v.normalize {
+		return line, ""
+	}
+	normalizeURL(u)
+	return u.String(), ""
+}
+
+// reject records a rejected line in the report file, if one was requested.
+func (v *urlValidator) reject(rec urlRecord, reason string) error {
+	v.rejected++
+	if v.reportPath == "" {
+		return nil
+	}
+	if v.writer == nil {
+		report, err := createOutput(v.reportPath)
+		if err != nil {
+			return fmt.Errorf("creating rejected report '%s': %w", v.reportPath, err)
+		}
+		v.report = report
+		v.writer = bufio.NewWriter(report)
+	}
+	// Source and reason first so that the raw line, which may contain tabs, comes last
+	if _, err := fmt.Fprintf(v.writer, "%s\t%s\t%s\n", rec.Source, reason, rec.URL); err != nil {
+		return fmt.Errorf("writing rejected report '%s': %w", v.reportPath, err)
+	}
+	return nil
+}
+
+// close flushes and closes the report file if one was created.
+func (v *urlValidator) close() error {
+	if v.report == nil {
+		return nil
+	}
+	if err := v.writer.Flush(); err != nil {
+		v.report.Close()
+		return fmt.Errorf("writing rejected report '%s': %w", v.reportPath, err)
+	}
+	return v.report.Close()
+}
+
+// normalizeURL lowercases the scheme and host and drops the port when it is
+// the default for the scheme.
+func normalizeURL(u *url.URL) {
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+}
+
+// validHostname reports whether host is an IP address or a plausible DNS name.
+func validHostname(host string) bool {
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	if len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+		for _, r := range label {
+			// Underscores aren't valid in hostnames but show up in real-world DNS
+			if !(r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > unicode.MaxASCII) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// unwrapURLError strips the "parse <url>:" prefix that net/url adds to errors.
+func unwrapURLError(err error) string {
+	if urlErr, ok := err.(*url.Error); ok {
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}